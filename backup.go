@@ -0,0 +1,68 @@
+package buckets
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// Backup writes a consistent snapshot of the entire database to `w`,
+// returning the number of bytes written.  The snapshot is taken within a
+// read-only transaction, so it reflects the database at a single point
+// in time, even while other transactions continue to write.  The
+// snapshot is itself a bolt database file, which can be opened with Open.
+func (db *DB) Backup(w io.Writer) (n int64, err error) {
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// BackupHandler returns an http.HandlerFunc that responds with a snapshot
+// of the database, as written by Backup, for downloading as an attachment.
+func (db *DB) BackupHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		err := db.DB.View(func(tx *bolt.Tx) error {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition",
+				fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(db.Path())))
+			w.Header().Set("Content-Length", strconv.FormatInt(tx.Size(), 10))
+			_, err := tx.WriteTo(w)
+			return err
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Restore writes a database snapshot, as written by Backup, from `r` to a
+// new file at `path`, then opens it.  Restore won't overwrite an existing
+// file.  If the snapshot can't be written or opened, the new file is
+// removed.
+func Restore(path string, r io.Reader) (*DB, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	db, err := Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,134 @@
+package buckets_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure a backup can be opened as a standalone database.
+func TestBackup(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+
+	path := tempfile()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(path)
+	n, err := bx.Backup(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	f.Close()
+	if n == 0 {
+		t.Error("got 0 bytes written")
+	}
+
+	backup, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer backup.Close()
+
+	restored, err := backup.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := restored.Get([]byte("A")); !bytes.Equal(v, []byte("alpha")) {
+		t.Errorf("got %q, want %q", v, "alpha")
+	}
+}
+
+// Ensure a backup can be restored to a new database file.
+func TestRestore(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+
+	var buf bytes.Buffer
+	if _, err := bx.Backup(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	path := tempfile()
+	restored, err := buckets.Restore(path, &buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(path)
+	defer restored.Close()
+
+	bk, err := restored.Bucket([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := bk.Get([]byte("A")); !bytes.Equal(v, []byte("alpha")) {
+		t.Errorf("got %q, want %q", v, "alpha")
+	}
+
+	// Restoring over an existing file fails.
+	if _, err := buckets.Restore(path, strings.NewReader("")); err == nil {
+		t.Error("expected error restoring over an existing file")
+	}
+}
+
+// Ensure the backup handler serves a snapshot as an attachment.
+func TestBackupHandler(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	if _, err := bx.New([]byte("things")); err != nil {
+		t.Error(err.Error())
+	}
+
+	srv := httptest.NewServer(bx.BackupHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+		t.Errorf("got Content-Disposition %q, want attachment", cd)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	var buf bytes.Buffer
+	if _, err := bx.Backup(&buf); err != nil {
+		t.Error(err.Error())
+	}
+	if len(body) != buf.Len() {
+		t.Errorf("got %d bytes, want %d", len(body), buf.Len())
+	}
+}
@@ -2,10 +2,16 @@ package buckets_test
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
+	"path"
+	"reflect"
 	"testing"
 
+	"github.com/boltdb/bolt"
 	"github.com/joyrexus/buckets"
 )
 
@@ -24,6 +30,64 @@ func TestBucket(t *testing.T) {
 	}
 }
 
+// Ensure we can run several operations in a single transaction.
+func TestUpdateView(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Read a value and write a dependent one atomically.
+	err = things.Update(func(b *bolt.Bucket) error {
+		if err := b.Put([]byte("A"), []byte("alpha")); err != nil {
+			return err
+		}
+		v := b.Get([]byte("A"))
+		return b.Put([]byte("B"), append([]byte("after "), v...))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	var got []byte
+	err = things.View(func(b *bolt.Bucket) error {
+		got = append(got, b.Get([]byte("B"))...)
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if want := []byte("after alpha"); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// An error rolls back the transaction.
+	failed := fmt.Errorf("failed")
+	err = things.Update(func(b *bolt.Bucket) error {
+		if err := b.Put([]byte("C"), []byte("gamma")); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+	if v, _ := things.Get([]byte("C")); v != nil {
+		t.Errorf("not expecting value for key %q: got %q", "C", v)
+	}
+
+	// Writes within a View are rejected.
+	err = things.View(func(b *bolt.Bucket) error {
+		return b.Put([]byte("D"), []byte("delta"))
+	})
+	if err != bolt.ErrTxNotWritable {
+		t.Errorf("got error %v, want %v", err, bolt.ErrTxNotWritable)
+	}
+}
+
 // Ensure we can put an item in a bucket.
 func TestPut(t *testing.T) {
 	bx := NewTestDB()
@@ -109,6 +173,87 @@ func TestPutNX(t *testing.T) {
 	}
 }
 
+// Ensure PutIfAbsent reports whether it inserted a value.
+func TestPutIfAbsent(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("A")
+	a, b := []byte("alpha"), []byte("beta")
+
+	inserted, err := things.PutIfAbsent(key, a)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !inserted {
+		t.Errorf("value for missing key %q not inserted", key)
+	}
+
+	inserted, err = things.PutIfAbsent(key, b)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if inserted {
+		t.Errorf("value for existing key %q inserted", key)
+	}
+
+	got, err := things.Get(key)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !bytes.Equal(got, a) {
+		t.Errorf("got %q, want %q", got, a)
+	}
+}
+
+// Ensure CompareAndSwap only sets values that are unchanged.
+func TestCompareAndSwap(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	locks, err := bx.New([]byte("locks"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("lock")
+	tests := []struct {
+		old, new []byte
+		want     bool
+	}{
+		{nil, []byte("alice"), true},             // claim the lock
+		{nil, []byte("bob"), false},              // already claimed
+		{[]byte("bob"), []byte("carol"), false},  // wrong owner
+		{[]byte("alice"), []byte("carol"), true}, // hand off the lock
+		{[]byte{}, []byte("dave"), false},        // empty isn't absent
+		{[]byte("carol"), []byte{}, true},        // release to empty
+		{[]byte{}, []byte("erin"), true},         // claim from empty
+	}
+
+	for _, tt := range tests {
+		swapped, err := locks.CompareAndSwap(key, tt.old, tt.new)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if swapped != tt.want {
+			t.Errorf("swap %q for %q: got %v, want %v", tt.old, tt.new, swapped, tt.want)
+		}
+	}
+
+	got, err := locks.Get(key)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if want := []byte("erin"); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 // Show we don't overwrite existing values when using PutNX.
 func ExampleBucket_PutNX() {
 	bx, _ := buckets.Open(tempfile())
@@ -143,6 +288,30 @@ func ExampleBucket_PutNX() {
 	// The value of "A" in `things` is still "alpha"
 }
 
+// Show that PutIfAbsent only puts a value for a new key, reporting
+// whether it did.
+func ExampleBucket_PutIfAbsent() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	// Create a new `ids` bucket.
+	ids, _ := bx.New([]byte("ids"))
+
+	// Claim a unique id, then try claiming it again.
+	for _, owner := range []string{"alice", "bob"} {
+		inserted, err := ids.PutIfAbsent([]byte("id-1"), []byte(owner))
+		if err != nil {
+			fmt.Printf("could not insert item: %v", err)
+		}
+		fmt.Printf("%s claimed id-1: %v\n", owner, inserted)
+	}
+
+	// Output:
+	// alice claimed id-1: true
+	// bob claimed id-1: false
+}
+
 // Ensure that a bucket that gets a non-existent key returns nil.
 func TestGetMissing(t *testing.T) {
 	bx := NewTestDB()
@@ -159,8 +328,8 @@ func TestGetMissing(t *testing.T) {
 	}
 }
 
-// Ensure that we can delete stuff in a bucket.
-func TestDelete(t *testing.T) {
+// Ensure that we can get several keys at once, in order.
+func TestGetBatch(t *testing.T) {
 	bx := NewTestDB()
 	defer bx.Close()
 
@@ -169,214 +338,1408 @@ func TestDelete(t *testing.T) {
 		t.Error(err.Error())
 	}
 
-	k, v := []byte("foo"), []byte("bar")
-	if err = things.Put(k, v); err != nil {
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+		{[]byte("E"), []byte{}},
+	}
+	if err := things.Insert(items); err != nil {
 		t.Error(err.Error())
 	}
 
-	if err = things.Delete(k); err != nil {
+	keys := [][]byte{[]byte("B"), []byte("missing"), []byte("A"), []byte("E")}
+	expected := [][]byte{[]byte("beta"), nil, []byte("alpha"), []byte{}}
+
+	results, err := things.GetBatch(keys)
+	if err != nil {
 		t.Error(err.Error())
 	}
+	if len(results) != len(keys) {
+		t.Fatalf("got %d items, want %d", len(results), len(keys))
+	}
+	for i, want := range expected {
+		got := results[i]
+		if !bytes.Equal(got.Key, keys[i]) {
+			t.Errorf("got key %q, want %q", got.Key, keys[i])
+		}
+		if !bytes.Equal(got.Value, want) || (got.Value == nil) != (want == nil) {
+			t.Errorf("key %q: got %q, want %q", got.Key, got.Value, want)
+		}
+	}
 }
 
-// Ensure we can insert items into a bucket and get them back out.
-func TestInsert(t *testing.T) {
+// Ensure we can get items for several keys, with nil for missing keys.
+func TestItemsByKeys(t *testing.T) {
 	bx := NewTestDB()
 	defer bx.Close()
 
-	paths, err := bx.New([]byte("paths"))
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
 
-	// k, v pairs to put in `paths` bucket
 	items := []struct {
 		Key, Value []byte
 	}{
-		{[]byte("foo/"), []byte("foo")},
-		{[]byte("foo/bar/"), []byte("bar")},
-		{[]byte("foo/bar/baz/"), []byte("baz")},
-		{[]byte("food/"), []byte("")},
-		{[]byte("good/"), []byte("")},
-		{[]byte("goo/"), []byte("")},
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+		{[]byte("E"), []byte{}},
 	}
-
-	err = paths.Insert(items)
-	if err != nil {
+	if err := things.Insert(items); err != nil {
 		t.Error(err.Error())
 	}
 
-	gotItems, err := paths.Items()
+	keys := [][]byte{[]byte("B"), []byte("missing"), []byte("A"), []byte("E")}
+	expected := [][]byte{[]byte("beta"), nil, []byte("alpha"), []byte{}}
+
+	results, err := things.ItemsByKeys(keys)
 	if err != nil {
 		t.Error(err.Error())
 	}
-
-	// expected k/v mapping
-	expected := map[string][]byte{
-		"foo/":         []byte("foo"),
-		"foo/bar/":     []byte("bar"),
-		"foo/bar/baz/": []byte("baz"),
-		"food/":        []byte(""),
-		"good/":        []byte(""),
-		"goo/":         []byte(""),
+	if len(results) != len(keys) {
+		t.Fatalf("got %d items, want %d", len(results), len(keys))
 	}
-
-	for _, item := range gotItems {
-		want := expected[string(item.Key)]
-		if !bytes.Equal(item.Value, want) {
-			t.Errorf("got %v, want %v", item.Value, want)
+	for i, want := range expected {
+		got := results[i]
+		if want == nil {
+			if got != nil {
+				t.Errorf("key %q: got %q, want nil item", keys[i], got.Value)
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("key %q: got nil item, want %q", keys[i], want)
+			continue
+		}
+		if !bytes.Equal(got.Key, keys[i]) || !bytes.Equal(got.Value, want) {
+			t.Errorf("got %q=%q, want %q=%q", got.Key, got.Value, keys[i], want)
 		}
 	}
 }
 
-// Show we can insert items into a bucket and get them back out.
-func ExampleBucket_Insert() {
+// Show that we can get the values for several keys, in order.
+func ExampleBucket_GetBatch() {
 	bx, _ := buckets.Open(tempfile())
 	defer os.Remove(bx.Path())
 	defer bx.Close()
 
 	letters, _ := bx.New([]byte("letters"))
 
-	// Setup items to insert in `letters` bucket.
-	items := []struct {
-		Key, Value []byte
-	}{
-		{[]byte("A"), []byte("alpha")},
-		{[]byte("B"), []byte("beta")},
-		{[]byte("C"), []byte("gamma")},
-	}
+	letters.Put([]byte("A"), []byte("alpha"))
+	letters.Put([]byte("C"), []byte("gamma"))
 
-	// Insert items into `letters` bucket.
-	if err := letters.Insert(items); err != nil {
-		fmt.Println("could not insert items!")
-	}
+	keys := [][]byte{[]byte("C"), []byte("B"), []byte("A")}
 
-	// Get items back out in separate read-only transaction.
-	results, _ := letters.Items()
+	// Get the values in a single read-only transaction.
+	items, _ := letters.GetBatch(keys)
 
-	for _, item := range results {
-		fmt.Printf("%s -> %s\n", item.Key, item.Value)
+	for i, item := range items {
+		if item.Value == nil {
+			fmt.Printf("%s is missing\n", keys[i])
+			continue
+		}
+		fmt.Printf("%s -> %s\n", keys[i], item.Value)
 	}
-
 	// Output:
-	// A -> alpha
-	// B -> beta
 	// C -> gamma
+	// B is missing
+	// A -> alpha
 }
 
-// Ensure we can safely insert items into a bucket without overwriting
-// existing items.
-func TestInsertNX(t *testing.T) {
+// Ensure that we can get a mapping of values for several keys.
+func TestGetMulti(t *testing.T) {
 	bx := NewTestDB()
 	defer bx.Close()
 
-	bk, err := bx.New([]byte("test"))
-
-	// Put k/v into the `bk` bucket.
-	k, v := []byte("A"), []byte("alpha")
-	if err := bk.Put(k, v); err != nil {
+	things, err := bx.New([]byte("things"))
+	if err != nil {
 		t.Error(err.Error())
 	}
 
-	// k/v pairs to put-if-not-exists
 	items := []struct {
 		Key, Value []byte
 	}{
-		{[]byte("A"), []byte("ALPHA")}, // key exists, so don't update
+		{[]byte("A"), []byte("alpha")},
 		{[]byte("B"), []byte("beta")},
 		{[]byte("C"), []byte("gamma")},
 	}
-
-	err = bk.InsertNX(items)
-	if err != nil {
+	if err := things.Insert(items); err != nil {
 		t.Error(err.Error())
 	}
 
-	gotItems, err := bk.Items()
+	keys := [][]byte{[]byte("A"), []byte("C"), []byte("missing")}
+	got, err := things.GetMulti(keys)
 	if err != nil {
 		t.Error(err.Error())
 	}
 
-	// expected items
-	expected := []struct {
-		Key, Value []byte
-	}{
-		{[]byte("A"), []byte("alpha")}, // existing value not updated
-		{[]byte("B"), []byte("beta")},
-		{[]byte("C"), []byte("gamma")},
+	expected := map[string][]byte{
+		"A": []byte("alpha"),
+		"C": []byte("gamma"),
 	}
-
-	for i, got := range gotItems {
-		want := expected[i]
-		if !bytes.Equal(got.Value, want.Value) {
-			t.Errorf("key %q: got %v, want %v", got.Key, got.Value, want.Value)
+	if len(got) != len(expected) {
+		t.Errorf("got %d values, want %d", len(got), len(expected))
+	}
+	for k, want := range expected {
+		if !bytes.Equal(got[k], want) {
+			t.Errorf("key %q: got %q, want %q", k, got[k], want)
 		}
 	}
 }
 
-// Ensure that we can get items for all keys with a given prefix.
-func TestPrefixItems(t *testing.T) {
+// Ensure that we can check whether keys exist in a bucket.
+func TestHas(t *testing.T) {
 	bx := NewTestDB()
 	defer bx.Close()
 
-	// Create a new things bucket.
 	things, err := bx.New([]byte("things"))
 	if err != nil {
 		t.Error(err.Error())
 	}
 
-	// Setup items to insert.
-	items := []struct {
-		Key, Value []byte
-	}{
-		{[]byte("A"), []byte("1")},   // `A` prefix match
-		{[]byte("AA"), []byte("2")},  // match
-		{[]byte("AAA"), []byte("3")}, // match
-		{[]byte("AAB"), []byte("2")}, // match
-		{[]byte("B"), []byte("O")},
-		{[]byte("BA"), []byte("0")},
-		{[]byte("BAA"), []byte("0")},
+	if err = things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
 	}
-
-	// Insert 'em.
-	if err := things.Insert(items); err != nil {
+	// A present key with an empty value should still exist.
+	if err = things.Put([]byte("E"), []byte{}); err != nil {
 		t.Error(err.Error())
 	}
 
-	// Now get each item whose key starts with "A".
-	prefix := []byte("A")
+	expected := map[string]bool{
+		"A":       true,
+		"E":       true,
+		"missing": false,
+	}
 
-	// Expected items for keys with prefix "A".
-	expected := []struct {
-		Key, Value []byte
-	}{
-		{[]byte("A"), []byte("1")},
-		{[]byte("AA"), []byte("2")},
-		{[]byte("AAA"), []byte("3")},
-		{[]byte("AAB"), []byte("2")},
+	for k, want := range expected {
+		got, err := things.Has([]byte(k))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != want {
+			t.Errorf("key %q: got %v, want %v", k, got, want)
+		}
 	}
+}
 
-	results, err := things.PrefixItems(prefix)
+// Ensure that Exists only matches keys exactly.
+func TestExists(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
 	if err != nil {
 		t.Error(err.Error())
 	}
 
-	for i, want := range expected {
-		got := results[i]
-		if !bytes.Equal(got.Key, want.Key) {
-			t.Errorf("got %v, want %v", got.Key, want.Key)
+	if err = things.Put([]byte("AA"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if err = things.Put([]byte("E"), []byte{}); err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := map[string]bool{
+		"AA": true,
+		"E":  true,
+		"A":  false, // seeks to `AA`, which isn't an exact match
+		"Z":  false, // seeks past the last key
+	}
+
+	for k, want := range expected {
+		got, err := things.Exists([]byte(k))
+		if err != nil {
+			t.Error(err.Error())
 		}
-		if !bytes.Equal(got.Value, want.Value) {
-			t.Errorf("got %v, want %v", got.Value, want.Value)
+		if got != want {
+			t.Errorf("key %q: got %v, want %v", k, got, want)
 		}
 	}
 }
 
-// Show that we can get items for all keys with a given prefix.
-func ExampleBucket_PrefixItems() {
-	bx, _ := buckets.Open(tempfile())
-	defer os.Remove(bx.Path())
+// Ensure we can get the size of a value without getting the value.
+func TestSizeOf(t *testing.T) {
+	bx := NewTestDB()
 	defer bx.Close()
 
-	// Create a new things bucket.
-	things, _ := bx.New([]byte("things"))
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if err = things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if err = things.Put([]byte("E"), []byte{}); err != nil {
+		t.Error(err.Error())
+	}
+
+	for k, want := range map[string]int{"A": 5, "E": 0} {
+		got, err := things.SizeOf([]byte(k))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != want {
+			t.Errorf("key %q: got size %d, want %d", k, got, want)
+		}
+	}
+
+	if _, err := things.SizeOf([]byte("Z")); err != buckets.ErrKeyNotFound {
+		t.Errorf("got %v, want ErrKeyNotFound", err)
+	}
+}
+
+// Ensure we can atomically update a value with a func of its old value.
+func TestUpdateValue(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	counters, err := bx.New([]byte("counters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	incr := func(old []byte) ([]byte, error) {
+		n := make([]byte, 8)
+		if old != nil {
+			binary.LittleEndian.PutUint64(n, binary.LittleEndian.Uint64(old)+1)
+		} else {
+			binary.LittleEndian.PutUint64(n, 1)
+		}
+		return n, nil
+	}
+
+	k := []byte("hits")
+	for i := 0; i < 3; i++ {
+		if err := counters.UpdateValue(k, incr); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	v, err := counters.Get(k)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got := binary.LittleEndian.Uint64(v); got != 3 {
+		t.Errorf("got %d, want 3", got)
+	}
+
+	// An error from the func leaves the value unchanged.
+	failed := errors.New("failed")
+	err = counters.UpdateValue(k, func(old []byte) ([]byte, error) {
+		return []byte("bogus"), failed
+	})
+	if err != failed {
+		t.Errorf("got %v, want %v", err, failed)
+	}
+	if got, _ := counters.Get(k); !bytes.Equal(got, v) {
+		t.Errorf("got %q, want %q", got, v)
+	}
+}
+
+// Ensure we can atomically update the values of keys with a prefix.
+func TestUpdatePrefix(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("fruit/apple"), []byte("red")},
+		{[]byte("fruit/banana"), []byte("yellow")},
+		{[]byte("veg/kale"), []byte("green")},
+	}
+	if err = things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	upper := func(k, v []byte) ([]byte, error) {
+		return bytes.ToUpper(v), nil
+	}
+	n, err := things.UpdatePrefix([]byte("fruit/"), upper)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if n != 2 {
+		t.Errorf("updated %d keys, want 2", n)
+	}
+	for k, want := range map[string]string{
+		"fruit/apple":  "RED",
+		"fruit/banana": "YELLOW",
+		"veg/kale":     "green",
+	} {
+		if got, _ := things.Get([]byte(k)); string(got) != want {
+			t.Errorf("key %q: got %q, want %q", k, got, want)
+		}
+	}
+
+	// An error from the transform leaves all values unchanged.
+	failed := errors.New("failed")
+	n, err = things.UpdatePrefix([]byte("fruit/"), func(k, v []byte) ([]byte, error) {
+		if string(k) == "fruit/banana" {
+			return nil, failed
+		}
+		return []byte("bogus"), nil
+	})
+	if err != failed {
+		t.Errorf("got %v, want %v", err, failed)
+	}
+	if n != 0 {
+		t.Errorf("got count %d after failed update, want 0", n)
+	}
+	if got, _ := things.Get([]byte("fruit/apple")); string(got) != "RED" {
+		t.Errorf("got %q after failed update, want RED", got)
+	}
+}
+
+// Ensure we can get the value at a position in key order.
+func TestValueAt(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("C"), []byte("charlie")},
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("bravo")},
+	}
+	if err = letters.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	for n, want := range []string{"alpha", "bravo", "charlie"} {
+		got, err := letters.ValueAt(n)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if string(got) != want {
+			t.Errorf("position %d: got %s, want %s", n, got, want)
+		}
+	}
+
+	for _, n := range []int{-1, 3} {
+		if _, err := letters.ValueAt(n); err != buckets.ErrOutOfRange {
+			t.Errorf("position %d: got %v, want ErrOutOfRange", n, err)
+		}
+	}
+}
+
+// Ensure we can get the first and last items in a bucket.
+func TestFirstLast(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	k, v, err := letters.First()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if k != nil || v != nil {
+		t.Errorf("empty bucket: got first %q/%q, want nil", k, v)
+	}
+	k, v, err = letters.Last()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if k != nil || v != nil {
+		t.Errorf("empty bucket: got last %q/%q, want nil", k, v)
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("C"), []byte("charlie")},
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("bravo")},
+	}
+	if err = letters.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	k, v, err = letters.First()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if string(k) != "A" || string(v) != "alpha" {
+		t.Errorf("got first %q/%q, want A/alpha", k, v)
+	}
+	k, v, err = letters.Last()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if string(k) != "C" || string(v) != "charlie" {
+		t.Errorf("got last %q/%q, want C/charlie", k, v)
+	}
+}
+
+// Ensure we can slice items by position, including from the end.
+func TestSlice(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("bravo")},
+		{[]byte("C"), []byte("charlie")},
+		{[]byte("D"), []byte("delta")},
+	}
+	if err = letters.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{0, 2, "AB"},
+		{1, 3, "BC"},
+		{2, 10, "CD"},
+		{-2, 4, "CD"},
+		{0, -1, "ABC"},
+		{-10, 1, "A"},
+		{3, 1, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := letters.Slice(tt.start, tt.end)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		var keys string
+		for _, item := range got {
+			keys += string(item.Key)
+		}
+		if keys != tt.want {
+			t.Errorf("slice [%d:%d]: got %q, want %q", tt.start, tt.end, keys, tt.want)
+		}
+	}
+}
+
+// Ensure we can find the prefix common to all keys.
+func TestKeyPrefix(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	pre, err := paths.KeyPrefix()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if pre != nil {
+		t.Errorf("empty bucket: got %q, want nil", pre)
+	}
+
+	tests := []struct {
+		key, want string
+	}{
+		{"app/users/1", "app/users/1"},
+		{"app/users/2", "app/users/"},
+		{"app/orders/1", "app/"},
+	}
+
+	for _, tt := range tests {
+		if err := paths.Put([]byte(tt.key), []byte("")); err != nil {
+			t.Error(err.Error())
+		}
+		pre, err := paths.KeyPrefix()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if string(pre) != tt.want {
+			t.Errorf("after putting %s: got %q, want %q", tt.key, pre, tt.want)
+		}
+	}
+}
+
+// Ensure that we can delete stuff in a bucket.
+func TestDelete(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	k, v := []byte("foo"), []byte("bar")
+	if err = things.Put(k, v); err != nil {
+		t.Error(err.Error())
+	}
+
+	if err = things.Delete(k); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure that deleting a missing key is not an error.
+func TestDeleteMissing(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if err = things.Delete([]byte("missing")); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure that we can delete all keys with a given prefix.
+func TestDeletePrefix(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},   // `A` prefix match
+		{[]byte("AA"), []byte("2")},  // match
+		{[]byte("AAA"), []byte("3")}, // match
+		{[]byte("AAB"), []byte("2")}, // match
+		{[]byte("B"), []byte("O")},
+		{[]byte("BA"), []byte("0")},
+	}
+
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	count, err := things.DeletePrefix([]byte("A"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 4 {
+		t.Errorf("got %d deleted, want %d", count, 4)
+	}
+
+	remaining, err := things.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := []string{"B", "BA"}
+	if len(remaining) != len(expected) {
+		t.Fatalf("got %d items, want %d", len(remaining), len(expected))
+	}
+	for i, want := range expected {
+		if got := remaining[i].Key; string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+// Ensure we can delete a list of keys, skipping those that don't exist.
+func TestDeleteBatch(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	cache, err := bx.New([]byte("cache"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := cache.Put([]byte(k), []byte("value")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	if err := cache.Put([]byte("e"), []byte{}); err != nil {
+		t.Error(err.Error())
+	}
+
+	keys := [][]byte{[]byte("a"), []byte("x"), []byte("c"), []byte("c"), []byte("e")}
+	count, err := cache.DeleteBatch(keys)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 3 {
+		t.Errorf("got %d keys removed, want 3", count)
+	}
+	items, err := cache.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 2 || string(items[0].Key) != "b" || string(items[1].Key) != "d" {
+		t.Errorf("got %d items left, want b and d", len(items))
+	}
+
+	if count, err := cache.DeleteBatch(nil); err != nil || count != 0 {
+		t.Errorf("got %d, %v; want nothing removed", count, err)
+	}
+}
+
+// Ensure we can delete items within a key range.
+func TestDeleteRange(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},
+		{[]byte("B"), []byte("2")},  // in range
+		{[]byte("BA"), []byte("3")}, // in range
+		{[]byte("C"), []byte("4")},  // in range
+		{[]byte("CA"), []byte("5")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	count, err := things.DeleteRange([]byte("B"), []byte("C"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 3 {
+		t.Errorf("got %d deleted, want %d", count, 3)
+	}
+
+	keys, err := things.NewPrefixScanner(nil).Keys()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	expected := []string{"A", "CA"}
+	if len(keys) != len(expected) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(expected))
+	}
+	for i, want := range expected {
+		if got := keys[i]; string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+
+	// Nil bounds leave the range open.
+	count, err = things.DeleteRange(nil, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 2 {
+		t.Errorf("got %d deleted, want %d", count, 2)
+	}
+}
+
+// Show that we can expire time-series items older than a cutoff.
+func ExampleBucket_DeleteRange() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	logs, _ := bx.New([]byte("logs"))
+
+	logs.Put([]byte("2016-01-01"), []byte("started"))
+	logs.Put([]byte("2016-02-01"), []byte("upgraded"))
+	logs.Put([]byte("2016-03-01"), []byte("restarted"))
+
+	// Delete everything up to and including the cutoff.
+	n, _ := logs.DeleteRange(nil, []byte("2016-02-01"))
+	fmt.Printf("deleted %d items\n", n)
+
+	items, _ := logs.Items()
+	for _, item := range items {
+		fmt.Printf("%s: %s\n", item.Key, item.Value)
+	}
+	// Output:
+	// deleted 2 items
+	// 2016-03-01: restarted
+}
+
+// Ensure we can clear all items from a bucket.
+func TestClear(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	idx, err := bx.NewSecondaryIndex(things, []byte("things-by-value"), func(k, v []byte) []byte {
+		return v
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var deleted []string
+	bx.Watcher().OnDelete([]byte("things"), []byte("A"), func() {
+		deleted = append(deleted, "A")
+	})
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},
+		{[]byte("B"), []byte("2")},
+		{[]byte("C"), []byte("3")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := things.NextSequence(); err != nil {
+		t.Error(err.Error())
+	}
+
+	count, err := things.Clear()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 3 {
+		t.Errorf("got %d cleared, want %d", count, 3)
+	}
+	if n, _ := things.Count(); n != 0 {
+		t.Errorf("got %d keys after clear, want 0", n)
+	}
+	if k, _ := idx.Lookup([]byte("1")); k != nil {
+		t.Errorf("got index entry %q after clear, want none", k)
+	}
+	if len(deleted) != 1 {
+		t.Errorf("got %d delete notifications, want 1", len(deleted))
+	}
+
+	// The bucket is still usable, with its sequence reset.
+	seq, err := things.NextSequence()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if seq != 1 {
+		t.Errorf("got sequence %d after clear, want 1", seq)
+	}
+	if err := things.Put([]byte("D"), []byte("4")); err != nil {
+		t.Error(err.Error())
+	}
+	if k, _ := idx.Lookup([]byte("4")); string(k) != "D" {
+		t.Errorf("got index entry %q, want D", k)
+	}
+}
+
+// Show that we can delete all items for keys with a given prefix.
+func ExampleBucket_DeletePrefix() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	todos, _ := bx.New([]byte("todos"))
+
+	// Setup todos to insert, keyed by day.
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("/mon/09:00"), []byte("standup")},
+		{[]byte("/mon/12:00"), []byte("lunch")},
+		{[]byte("/tue/09:00"), []byte("standup")},
+	}
+
+	if err := todos.Insert(items); err != nil {
+		fmt.Printf("could not insert items: %v\n", err)
+	}
+
+	// Clear Monday in a single transaction.
+	count, _ := todos.DeletePrefix([]byte("/mon/"))
+	fmt.Printf("deleted %d todos\n", count)
+
+	results, _ := todos.Items()
+	for _, item := range results {
+		fmt.Printf("%s -> %s\n", item.Key, item.Value)
+	}
+	// Output:
+	// deleted 2 todos
+	// /tue/09:00 -> standup
+}
+
+// Ensure that we can delete many keys using several workers.
+func TestBulkDelete(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	var items []struct {
+		Key, Value []byte
+	}
+	var keys [][]byte
+	for i := 0; i < 50; i++ {
+		k := []byte(fmt.Sprintf("key-%02d", i))
+		items = append(items, struct{ Key, Value []byte }{k, k})
+		if i%5 != 0 {
+			keys = append(keys, k)
+		}
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	if err := things.BulkDelete(keys, 4); err != nil {
+		t.Error(err.Error())
+	}
+
+	remaining, err := things.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(remaining) != 10 {
+		t.Fatalf("got %d items, want %d", len(remaining), 10)
+	}
+	for i, item := range remaining {
+		if want := fmt.Sprintf("key-%02d", i*5); string(item.Key) != want {
+			t.Errorf("got %s, want %s", item.Key, want)
+		}
+	}
+
+	// Deleting no keys is a no-op.
+	if err := things.BulkDelete(nil, 4); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure we can insert items into a bucket and get them back out.
+func TestInsert(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+
+	// k, v pairs to put in `paths` bucket
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("foo/"), []byte("foo")},
+		{[]byte("foo/bar/"), []byte("bar")},
+		{[]byte("foo/bar/baz/"), []byte("baz")},
+		{[]byte("food/"), []byte("")},
+		{[]byte("good/"), []byte("")},
+		{[]byte("goo/"), []byte("")},
+	}
+
+	err = paths.Insert(items)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	gotItems, err := paths.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// expected k/v mapping
+	expected := map[string][]byte{
+		"foo/":         []byte("foo"),
+		"foo/bar/":     []byte("bar"),
+		"foo/bar/baz/": []byte("baz"),
+		"food/":        []byte(""),
+		"good/":        []byte(""),
+		"goo/":         []byte(""),
+	}
+
+	for _, item := range gotItems {
+		want := expected[string(item.Key)]
+		if !bytes.Equal(item.Value, want) {
+			t.Errorf("got %v, want %v", item.Value, want)
+		}
+	}
+}
+
+// Show we can insert items into a bucket and get them back out.
+func ExampleBucket_Insert() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	letters, _ := bx.New([]byte("letters"))
+
+	// Setup items to insert in `letters` bucket.
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+		{[]byte("C"), []byte("gamma")},
+	}
+
+	// Insert items into `letters` bucket.
+	if err := letters.Insert(items); err != nil {
+		fmt.Println("could not insert items!")
+	}
+
+	// Get items back out in separate read-only transaction.
+	results, _ := letters.Items()
+
+	for _, item := range results {
+		fmt.Printf("%s -> %s\n", item.Key, item.Value)
+	}
+
+	// Output:
+	// A -> alpha
+	// B -> beta
+	// C -> gamma
+}
+
+// Ensure we can safely insert items into a bucket without overwriting
+// existing items.
+func TestInsertNX(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	bk, err := bx.New([]byte("test"))
+
+	// Put k/v into the `bk` bucket.
+	k, v := []byte("A"), []byte("alpha")
+	if err := bk.Put(k, v); err != nil {
+		t.Error(err.Error())
+	}
+
+	// k/v pairs to put-if-not-exists
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("ALPHA")}, // key exists, so don't update
+		{[]byte("B"), []byte("beta")},
+		{[]byte("C"), []byte("gamma")},
+	}
+
+	err = bk.InsertNX(items)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	gotItems, err := bk.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// expected items
+	expected := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")}, // existing value not updated
+		{[]byte("B"), []byte("beta")},
+		{[]byte("C"), []byte("gamma")},
+	}
+
+	for i, got := range gotItems {
+		want := expected[i]
+		if !bytes.Equal(got.Value, want.Value) {
+			t.Errorf("key %q: got %v, want %v", got.Key, got.Value, want.Value)
+		}
+	}
+}
+
+// Ensure that we can count the items in a bucket.
+func TestCount(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	count, err := things.Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 0 {
+		t.Errorf("got count %d, want %d", count, 0)
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+		{[]byte("C"), []byte("gamma")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	count, err = things.Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != len(items) {
+		t.Errorf("got count %d, want %d", count, len(items))
+	}
+}
+
+// Ensure that we can count the items with a given key prefix.
+func TestPrefixCount(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("/mon/09:00"), []byte("standup")},
+		{[]byte("/mon/12:00"), []byte("lunch")},
+		{[]byte("/tue/09:00"), []byte("standup")},
+	}
+	if err := todos.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := map[string]int{
+		"/mon/": 2,
+		"/tue/": 1,
+		"/wed/": 0,
+		"":      3,
+	}
+
+	for pre, want := range expected {
+		got, err := todos.PrefixCount([]byte(pre))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != want {
+			t.Errorf("prefix %q: got count %d, want %d", pre, got, want)
+		}
+	}
+}
+
+// Ensure that we can get the keys of a bucket in a custom order.
+func TestSortedKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	nums, err := bx.New([]byte("nums"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1"), []byte("one")},
+		{[]byte("10"), []byte("ten")},
+		{[]byte("2"), []byte("two")},
+		{[]byte("20"), []byte("twenty")},
+	}
+	if err := nums.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Sort numerically rather than in byte order.
+	numeric := func(a, b []byte) bool {
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return bytes.Compare(a, b) < 0
+	}
+
+	keys, err := nums.SortedKeys(numeric)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := []string{"1", "2", "10", "20"}
+	if len(keys) != len(expected) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(expected))
+	}
+	for i, want := range expected {
+		if got := keys[i]; string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+// Ensure we can put a batch of items in one or more transactions.
+func TestPutBatch(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []buckets.Item{
+		{Key: []byte("A"), Value: []byte("alpha")},
+		{Key: []byte("B"), Value: []byte("beta")},
+		{Key: []byte("C"), Value: []byte("gamma")},
+	}
+
+	if err := letters.PutBatch(items[:1]); err != nil {
+		t.Error(err.Error())
+	}
+	if err := letters.PutBatchN(items[1:], 1); err != nil {
+		t.Error(err.Error())
+	}
+
+	results, err := letters.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(results) != len(items) {
+		t.Fatalf("got %d items, want %d", len(results), len(items))
+	}
+	for i, want := range items {
+		got := results[i]
+		if !bytes.Equal(got.Key, want.Key) {
+			t.Errorf("got %s, want %s", got.Key, want.Key)
+		}
+		if !bytes.Equal(got.Value, want.Value) {
+			t.Errorf("got %s, want %s", got.Value, want.Value)
+		}
+	}
+
+	// A batch containing an invalid item shouldn't save any items.
+	bad := []buckets.Item{
+		{Key: []byte("D"), Value: []byte("delta")},
+		{Key: []byte{}, Value: []byte("empty key")},
+	}
+	if err := letters.PutBatch(bad); err == nil {
+		t.Error("expected error for item with empty key")
+	}
+	if got, _ := letters.Get([]byte("D")); got != nil {
+		t.Errorf("not expecting value for key %q: got %q", "D", got)
+	}
+}
+
+// Ensure that we can get items for all keys with a given prefix.
+func TestPrefixItems(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	// Create a new things bucket.
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Setup items to insert.
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},   // `A` prefix match
+		{[]byte("AA"), []byte("2")},  // match
+		{[]byte("AAA"), []byte("3")}, // match
+		{[]byte("AAB"), []byte("2")}, // match
+		{[]byte("B"), []byte("O")},
+		{[]byte("BA"), []byte("0")},
+		{[]byte("BAA"), []byte("0")},
+	}
+
+	// Insert 'em.
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Now get each item whose key starts with "A".
+	prefix := []byte("A")
+
+	// Expected items for keys with prefix "A".
+	expected := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},
+		{[]byte("AA"), []byte("2")},
+		{[]byte("AAA"), []byte("3")},
+		{[]byte("AAB"), []byte("2")},
+	}
+
+	results, err := things.PrefixItems(prefix)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	for i, want := range expected {
+		got := results[i]
+		if !bytes.Equal(got.Key, want.Key) {
+			t.Errorf("got %v, want %v", got.Key, want.Key)
+		}
+		if !bytes.Equal(got.Value, want.Value) {
+			t.Errorf("got %v, want %v", got.Value, want.Value)
+		}
+	}
+}
+
+// Ensure we can filter items with a prefix by a predicate.
+func TestFilterPrefix(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},
+		{[]byte("AA"), []byte("2")},
+		{[]byte("AAA"), []byte("3")},
+		{[]byte("AAB"), []byte("2")},
+		{[]byte("B"), []byte("2")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	results, err := things.FilterPrefix([]byte("A"), func(k, v []byte) bool {
+		return string(v) == "2"
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := []string{"AA", "AAB"}
+	if len(results) != len(expected) {
+		t.Fatalf("got %d items, want %d", len(results), len(expected))
+	}
+	for i, want := range expected {
+		if got := results[i]; string(got.Key) != want || string(got.Value) != "2" {
+			t.Errorf("got %q, want key %s", got, want)
+		}
+	}
+}
+
+// Ensure we can get the keys matching a glob pattern.
+func TestMatchKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	schedule, err := bx.New([]byte("schedule"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("/mon/10am"), []byte("walk")},
+		{[]byte("/mon/9am"), []byte("coffee")},
+		{[]byte("/mon/9am/standup"), []byte("meet")},
+		{[]byte("/tue/9am"), []byte("coffee")},
+	}
+	if err := schedule.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/mon/*", []string{"/mon/10am", "/mon/9am"}},
+		{"/*/9am", []string{"/mon/9am", "/tue/9am"}},
+		{"/wed/*", nil},
+	}
+
+	for _, tt := range tests {
+		keys, err := schedule.MatchKeys(tt.pattern)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.pattern, keys, tt.want)
+			continue
+		}
+		for i, want := range tt.want {
+			if string(keys[i]) != want {
+				t.Errorf("%s: got %s, want %s", tt.pattern, keys[i], want)
+			}
+		}
+	}
+
+	if _, err := schedule.MatchKeys("/mon/["); err != path.ErrBadPattern {
+		t.Errorf("got %v, want ErrBadPattern", err)
+	}
+}
+
+// Ensure we can page through keys after a given key.
+func TestKeysAfter(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	for _, k := range []string{"A", "B", "C", "D", "E"} {
+		if err := letters.Put([]byte(k), []byte("x")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	tests := []struct {
+		after string
+		limit int
+		want  []string
+	}{
+		{"", 2, []string{"A", "B"}},
+		{"B", 2, []string{"C", "D"}},
+		{"BB", 2, []string{"C", "D"}},
+		{"C", 0, []string{"D", "E"}},
+		{"E", 2, nil},
+	}
+
+	for _, tt := range tests {
+		keys, err := letters.KeysAfter([]byte(tt.after), tt.limit)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("after %q: got %q, want %q", tt.after, keys, tt.want)
+			continue
+		}
+		for i, want := range tt.want {
+			if string(keys[i]) != want {
+				t.Errorf("after %q: got %s, want %s", tt.after, keys[i], want)
+			}
+		}
+	}
+}
+
+// Show that we can get items for all keys with a given prefix.
+func ExampleBucket_PrefixItems() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	// Create a new things bucket.
+	things, _ := bx.New([]byte("things"))
 
 	// Setup items to insert.
 	items := []struct {
@@ -520,3 +1883,387 @@ func ExampleBucket_RangeItems() {
 	// 1995 -> 95
 	// 2000 -> 00
 }
+
+// Ensure we can merge the items of one bucket into another, resolving
+// conflicts for keys present in both.
+func TestMergeAll(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	dst, err := bx.New([]byte("dst"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	src, err := bx.New([]byte("src"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	dstItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+	}
+	srcItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")}, // conflict, resolved to same value
+		{[]byte("B"), []byte("BETA")},  // conflict, resolved to new value
+		{[]byte("C"), []byte("gamma")}, // new key
+	}
+
+	if err := dst.Insert(dstItems); err != nil {
+		t.Error(err.Error())
+	}
+	if err := src.Insert(srcItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Always take the incoming value.
+	resolve := func(k, existing, incoming []byte) []byte {
+		return incoming
+	}
+
+	added, updated, err := dst.MergeAll(src, resolve)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if added != 1 {
+		t.Errorf("got %d added, want %d", added, 1)
+	}
+	if updated != 1 {
+		t.Errorf("got %d updated, want %d", updated, 1)
+	}
+
+	expected := map[string][]byte{
+		"A": []byte("alpha"),
+		"B": []byte("BETA"),
+		"C": []byte("gamma"),
+	}
+
+	for k, want := range expected {
+		got, err := dst.Get([]byte(k))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("key %q: got %q, want %q", k, got, want)
+		}
+	}
+
+	// Merging from or into a deleted bucket is an error.
+	if err := bx.Delete([]byte("src")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, _, err := dst.MergeAll(src, resolve); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v merging from deleted bucket, want ErrBucketNotFound", err)
+	}
+	if _, _, err := src.MergeAll(dst, resolve); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v merging into deleted bucket, want ErrBucketNotFound", err)
+	}
+}
+
+// Ensure we can merge one bucket into another, overwriting or resolving
+// conflicts.
+func TestMergeFrom(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	src, err := bx.New([]byte("src"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	srcItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},
+		{[]byte("B"), []byte("2")},
+	}
+	if err := src.Insert(srcItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		name  string
+		merge func(dst *buckets.Bucket) error
+		wantA string
+	}{
+		{"overwrite", func(dst *buckets.Bucket) error {
+			return dst.MergeFrom(src)
+		}, "1"},
+		{"resolve", func(dst *buckets.Bucket) error {
+			return dst.MergeFromWithConflict(src, func(existing, incoming []byte) []byte {
+				return append(append([]byte{}, existing...), incoming...)
+			})
+		}, "01"},
+	}
+
+	for _, tt := range tests {
+		dst, err := bx.New([]byte(tt.name))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := dst.Put([]byte("A"), []byte("0")); err != nil {
+			t.Error(err.Error())
+		}
+		if err := tt.merge(dst); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		for k, want := range map[string]string{"A": tt.wantA, "B": "2"} {
+			if got, _ := dst.Get([]byte(k)); string(got) != want {
+				t.Errorf("%s: key %s: got %q, want %q", tt.name, k, got, want)
+			}
+		}
+	}
+}
+
+// Ensure we can compact a bucket into another.
+func TestCompactTo(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	src, err := bx.NewTagged([]byte("src"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	dst, err := bx.NewTagged([]byte("dst"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Put keys in random order, then delete most of them, leaving the
+	// source bucket's pages sparse.
+	for _, i := range rand.Perm(2000) {
+		k := []byte(fmt.Sprintf("key%04d", i))
+		if err := src.Put(k, bytes.Repeat([]byte("v"), 50)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		if i%4 == 0 {
+			continue
+		}
+		if err := src.Delete([]byte(fmt.Sprintf("key%04d", i))); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	tags := map[string]string{"kind": "tagged"}
+	if err := src.TaggedPut([]byte("key0000"), []byte("zero"), tags); err != nil {
+		t.Error(err.Error())
+	}
+
+	if err := src.CompactTo(dst); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := src.CompactTo(src); err == nil {
+		t.Error("expected error compacting a bucket into itself")
+	}
+
+	srcItems, err := src.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	dstItems, err := dst.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !reflect.DeepEqual(srcItems, dstItems) {
+		t.Errorf("got %d items in copy, want %d", len(dstItems), len(srcItems))
+	}
+	if got, _ := dst.Tags([]byte("key0000")); !reflect.DeepEqual(got, tags) {
+		t.Errorf("got tags %v, want %v", got, tags)
+	}
+
+	srcStats, err := src.Stats()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	dstStats, err := dst.Stats()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if dstStats.LeafPageCount >= srcStats.LeafPageCount {
+		t.Errorf("got %d leaf pages in copy, want fewer than %d",
+			dstStats.LeafPageCount, srcStats.LeafPageCount)
+	}
+
+	// Compacting a deleted bucket is an error.
+	if err := bx.Delete([]byte("src")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := src.CompactTo(dst); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v compacting deleted bucket, want ErrBucketNotFound", err)
+	}
+}
+
+// Ensure we can shard the items of a bucket across several buckets.
+func TestShard(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	var items []struct {
+		Key, Value []byte
+	}
+	for i := 0; i < 100; i++ {
+		k := []byte(fmt.Sprintf("key-%03d", i))
+		items = append(items, struct{ Key, Value []byte }{k, k})
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	n := 3
+	shards := make([]*buckets.Bucket, n)
+	for i := range shards {
+		shards[i], err = bx.New([]byte(fmt.Sprintf("shard-%d", i)))
+		if err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	if err := things.Shard(n, shards); err != nil {
+		t.Error(err.Error())
+	}
+
+	// The original bucket should now be empty.
+	remaining, err := things.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(remaining) != 0 {
+		t.Errorf("got %d items left in sharded bucket, want 0", len(remaining))
+	}
+
+	// Every item should land in exactly one shard.
+	total := 0
+	for _, shard := range shards {
+		shardItems, err := shard.Items()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		for _, item := range shardItems {
+			if !bytes.Equal(item.Key, item.Value) {
+				t.Errorf("key %q: got %q, want %q", item.Key, item.Value, item.Key)
+			}
+		}
+		total += len(shardItems)
+	}
+	if total != len(items) {
+		t.Errorf("got %d sharded items, want %d", total, len(items))
+	}
+
+	// A shard count that doesn't match the shards given is an error.
+	if err := things.Shard(2, shards); err == nil {
+		t.Error("expected error for mismatched shard count")
+	}
+
+	// The bucket can't be one of its own shards.
+	if err := shards[0].Put([]byte("a"), []byte("1")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := shards[0].Shard(2, shards[:2]); err == nil {
+		t.Error("expected error for sharding a bucket into itself")
+	}
+	if ok, _ := shards[0].Has([]byte("a")); !ok {
+		t.Error("item lost sharding a bucket into itself")
+	}
+}
+
+// Ensure that we can create, use, and delete nested child buckets.
+func TestChild(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	tenants, err := bx.New([]byte("tenants"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := tenants.Put([]byte("count"), []byte("2")); err != nil {
+		t.Error(err.Error())
+	}
+
+	acme, err := tenants.NewChild([]byte("acme"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	todos, err := acme.NewChild([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("/mon/09:00"), []byte("standup")},
+		{[]byte("/mon/12:00"), []byte("lunch")},
+		{[]byte("/tue/09:00"), []byte("standup")},
+	}
+	if err := todos.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	got, err := todos.Get([]byte("/mon/12:00"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if want := []byte("lunch"); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	count, err := todos.NewPrefixScanner([]byte("/mon/")).Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 2 {
+		t.Errorf("got count %d, want %d", count, 2)
+	}
+
+	// Bucket is an alias for NewChild.
+	if _, err := tenants.Bucket([]byte("globex")); err != nil {
+		t.Error(err.Error())
+	}
+
+	// The children are listed by name.
+	names, err := tenants.SubBuckets()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(names) != 2 || string(names[0]) != "acme" || string(names[1]) != "globex" {
+		t.Errorf("got %q, want [acme globex]", names)
+	}
+
+	// The child's keys aren't among the parent's items.
+	parentItems, err := tenants.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(parentItems) != 1 || string(parentItems[0].Key) != "count" {
+		t.Errorf("got %q, want only the `count` item", parentItems)
+	}
+
+	// Reopening a child gives access to the same keys.
+	acme, err = tenants.NewChild([]byte("acme"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	reopened, err := acme.NewChild([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count, _ := reopened.Count(); count != len(items) {
+		t.Errorf("got count %d, want %d", count, len(items))
+	}
+
+	if err := tenants.DeleteChild([]byte("acme")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := acme.NewChild([]byte("todos")); err == nil {
+		t.Error("expected error creating child of deleted bucket")
+	}
+}
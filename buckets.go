@@ -3,6 +3,10 @@ package buckets
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -10,34 +14,134 @@ import (
 
 // A DB is a bolt database with convenience methods for working with buckets.
 //
-// A DB embeds the exposed bolt.DB methods.
+// A DB embeds the exposed bolt.DB methods, except for View, Begin, Batch,
+// and Stats, which DB hides with its own methods working with buckets
+// (e.g., View takes a func of a ReadTx rather than a bolt.Tx).  For the
+// bolt methods, call them on the embedded bolt.DB, e.g., db.DB.View.
 type DB struct {
 	*bolt.DB
+	watcher *Watcher
+	temp    bool // remove the file on Close
+
+	mu      sync.Mutex        // guards the fields below
+	closed  bool              // set by Close
+	stop    chan struct{}     // closed by Close to stop background goroutines
+	wg      sync.WaitGroup    // background goroutines
+	indexes []*SecondaryIndex // updated by writes to their primary buckets
+	sweeper *sweeper          // started by StartSweeper
+}
+
+// Open creates/opens a buckets database at the specified path.  By
+// default, the file is created with mode 0600, and opening fails if the
+// file is locked by another process for more than a second.  Options
+// (e.g., ReadOnly or WithTimeout) change these defaults.
+func Open(path string, opts ...Option) (*DB, error) {
+	o := options{mode: 0600, bolt: bolt.Options{Timeout: 1 * time.Second}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return OpenWith(path, o.mode, &o.bolt)
 }
 
-// Open creates/opens a buckets database at the specified path.
-func Open(path string) (*DB, error) {
-	config := &bolt.Options{Timeout: 1 * time.Second}
-	db, err := bolt.Open(path, 0600, config)
+// OpenWith creates/opens a buckets database at the specified path, passing
+// file mode `mode` and `options` through to bolt.  In particular, set
+// the Timeout option so that opening a file locked by another process
+// fails rather than blocking indefinitely.  Nil options use bolt's
+// defaults, under which opening a locked file blocks until it's unlocked.
+func OpenWith(path string, mode os.FileMode, options *bolt.Options) (*DB, error) {
+	db, err := bolt.Open(path, mode, options)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't open %s: %s", path, err)
 	}
-	return &DB{db}, nil
+	return &DB{DB: db, watcher: &Watcher{}}, nil
+}
+
+// OpenReadOnly opens an existing buckets database at the specified path
+// for reading only.  It's shorthand for Open with the ReadOnly option.
+func OpenReadOnly(path string) (*DB, error) {
+	return Open(path, ReadOnly())
+}
+
+// Close releases all database resources, first stopping any background
+// goroutines (e.g., those started by EnableTTL).  For a database opened
+// with OpenTemp, Close also removes the database file.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	db.closed = true
+	if db.stop != nil {
+		close(db.stop)
+		db.stop = nil
+	}
+	db.mu.Unlock()
+	db.wg.Wait()
+
+	path := db.Path()
+	err := db.DB.Close()
+	if db.temp {
+		if rerr := os.Remove(path); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// goroutine runs `do` in a background goroutine that Close stops, by
+// closing the channel passed to `do`, and then waits for.  If the
+// database is already closed, `do` isn't run.
+func (db *DB) goroutine(do func(stop <-chan struct{})) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return bolt.ErrDatabaseNotOpen
+	}
+	if db.stop == nil {
+		db.stop = make(chan struct{})
+	}
+	stop := db.stop
+	db.wg.Add(1)
+	go func() {
+		defer db.wg.Done()
+		do(stop)
+	}()
+	return nil
 }
 
-// New creates/opens a named bucket.
+// New creates/opens a named bucket.  An existing bucket is opened with
+// its persistent settings, e.g., as a TTL bucket if it was created with
+// NewTTL.
 func (db *DB) New(name []byte) (*Bucket, error) {
+	bk := &Bucket{db: db, Name: name}
 	err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(name)
+		b, err := tx.CreateBucketIfNotExists(name)
 		if err != nil {
 			return err
 		}
+		bk.loadSettings(b)
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &Bucket{db, name}, nil
+	return bk, nil
+}
+
+// Bucket opens the named bucket, without creating it.  If the bucket
+// doesn't exist, Bucket returns ErrBucketNotFound.  Like New, it opens
+// the bucket with its persistent settings.
+func (db *DB) Bucket(name []byte) (*Bucket, error) {
+	bk := &Bucket{db: db, Name: name}
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(name)
+		if b == nil {
+			return ErrBucketNotFound
+		}
+		bk.loadSettings(b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bk, nil
 }
 
 // Delete removes the named bucket.
@@ -47,6 +151,34 @@ func (db *DB) Delete(name []byte) error {
 	})
 }
 
+// Drop removes the named bucket.  It's an alias for Delete.
+func (db *DB) Drop(name []byte) error {
+	return db.Delete(name)
+}
+
+// List returns the names of the buckets in the database, in byte-sorted
+// order.  Nested buckets aren't included.
+func (db *DB) List() (names [][]byte, err error) {
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			n := make([]byte, len(name))
+			copy(n, name)
+			names = append(names, n)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Buckets returns the names of the buckets in the database, in
+// byte-sorted order.  It's an alias for List.
+func (db *DB) Buckets() ([][]byte, error) {
+	return db.List()
+}
+
 /* -- ITEM -- */
 
 // An Item holds a key/value pair.
@@ -55,41 +187,304 @@ type Item struct {
 	Value []byte
 }
 
+// NewItem returns an Item with key `key` and value `value`.
+func NewItem(key, value []byte) Item {
+	return Item{Key: key, Value: value}
+}
+
 /* -- BUCKET-- */
 
 // Bucket represents a collection of key/value pairs inside the database.
 type Bucket struct {
-	db   *DB
-	Name []byte
+	db         *DB
+	Name       []byte
+	parent     *Bucket
+	compressed bool
+	ttl        bool         // values have expiry headers (see NewTTL)
+	tagged     bool         // values have tags headers (see NewTagged)
+	fill       float64      // bolt FillPercent for writes, if non-zero
+	warm       *sync.Map    // keys known to exist, if warmed up
+	tx         *Tx          // transaction the bucket was opened within, if any
+	codec      Codec        // used by PutValue and GetValue (see WithCodec)
+	keyCodec   KeyCodec     // used by PutKey, GetKey, etc. (see WithKeyCodec)
+	maxKeys    int          // limit on keys added by writes (see WithMaxKeys)
+	mw         []Middleware // called around operations (see WithMiddleware)
+}
+
+// bucket returns the bolt bucket for bk within transaction `tx`, or nil
+// if the bucket (or one of its ancestors) doesn't exist.
+func (bk *Bucket) bucket(tx *bolt.Tx) *bolt.Bucket {
+	if bk.parent == nil {
+		return tx.Bucket(bk.Name)
+	}
+	if parent := bk.parent.bucket(tx); parent != nil {
+		return parent.Bucket(bk.Name)
+	}
+	return nil
+}
+
+// NewChild creates/opens a named bucket nested within the bucket.  The
+// child bucket's keys are separate from the keys of its parent.
+func (bk *Bucket) NewChild(name []byte) (child *Bucket, err error) {
+	defer bk.observe("NewChild", name)(&err)
+	child = &Bucket{db: bk.db, Name: name, parent: bk, tx: bk.tx}
+	err = bk.write(func(tx *bolt.Tx) error {
+		b := bk.bucket(tx)
+		if b == nil {
+			return ErrBucketNotFound
+		}
+		cb, err := b.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		child.loadSettings(cb)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return child, nil
+}
+
+// Bucket creates/opens a named bucket nested within the bucket.  It's
+// an alias for NewChild.
+func (bk *Bucket) Bucket(name []byte) (*Bucket, error) {
+	return bk.NewChild(name)
+}
+
+// SubBuckets returns the names of the buckets nested directly within the
+// bucket, in byte-sorted order.
+func (bk *Bucket) SubBuckets() (names [][]byte, err error) {
+	defer bk.observe("SubBuckets", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil && !bytes.Equal(k, settingsBucket) {
+				name := make([]byte, len(k))
+				copy(name, k)
+				names = append(names, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// DeleteChild removes the named bucket nested within the bucket.
+func (bk *Bucket) DeleteChild(name []byte) (err error) {
+	defer bk.observe("DeleteChild", name)(&err)
+	return bk.write(func(tx *bolt.Tx) error {
+		b := bk.bucket(tx)
+		if b == nil {
+			return ErrBucketNotFound
+		}
+		return b.DeleteBucket(name)
+	})
+}
+
+// update applies `do` on the bucket within a read-write transaction.
+// Once the transaction commits, the database's watchers are notified of
+// the changes made through the recorder passed to `do`.
+func (bk *Bucket) update(do func(b *recorder) error) error {
+	r := &recorder{
+		bk:      bk,
+		record:  bk.db.watcher.watching(),
+		indexes: bk.db.indexesFor(bk),
+	}
+	err := bk.write(func(tx *bolt.Tx) error {
+		r.Bucket = bk.bucket(tx)
+		if r.Bucket != nil {
+			if err := bk.checkSettings(r.Bucket); err != nil {
+				return err
+			}
+			if bk.fill != 0 {
+				r.Bucket.FillPercent = bk.fill
+			}
+		}
+		return do(r)
+	})
+	if err != nil {
+		return err
+	}
+	if bk.tx != nil {
+		bk.tx.changes = append(bk.tx.changes, txChanges{bk.path(), r.changes})
+		return nil
+	}
+	bk.db.watcher.notify(bk.path(), r.changes)
+	return nil
+}
+
+// view applies `do` within a read-only transaction, or within the Tx the
+// bucket was opened within.
+func (bk *Bucket) view(do func(tx *bolt.Tx) error) error {
+	if bk.tx != nil {
+		return bk.tx.run(do)
+	}
+	return bk.db.DB.View(do)
+}
+
+// write applies `do` within a read-write transaction, or within the Tx
+// the bucket was opened within.
+func (bk *Bucket) write(do func(tx *bolt.Tx) error) error {
+	if bk.tx != nil {
+		return bk.tx.write(do)
+	}
+	return bk.db.Update(do)
+}
+
+// Update applies `do` on the underlying bolt bucket within a read-write
+// transaction, for making several dependent changes atomically.  If `do`
+// returns an error, the transaction is rolled back.  The bolt bucket is
+// only valid while `do` runs and must not be retained.  Note that changes
+// made via Update aren't reported to the database's watchers or applied
+// to the bucket's secondary indexes.
+func (bk *Bucket) Update(do func(b *bolt.Bucket) error) (err error) {
+	defer bk.observe("Update", nil)(&err)
+	return bk.write(func(tx *bolt.Tx) error {
+		return do(bk.bucket(tx))
+	})
+}
+
+// View applies `do` on the underlying bolt bucket within a read-only
+// transaction.  Attempts to modify the bolt bucket return an error.  The
+// bolt bucket is only valid while `do` runs and must not be retained.
+// Note that values read via the bolt bucket are as stored, so values in
+// compressed buckets aren't decompressed.
+func (bk *Bucket) View(do func(b *bolt.Bucket) error) (err error) {
+	defer bk.observe("View", nil)(&err)
+	return bk.view(func(tx *bolt.Tx) error {
+		return do(bk.bucket(tx))
+	})
 }
 
 // Put inserts value `v` with key `k`.
-func (bk *Bucket) Put(k, v []byte) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).Put(k, v)
+func (bk *Bucket) Put(k, v []byte) (err error) {
+	defer bk.observe("Put", k)(&err)
+	return bk.update(func(b *recorder) error {
+		return b.Put(k, v)
 	})
 }
 
 // PutNX (put-if-not-exists) inserts value `v` with key `k`
 // if key doesn't exist.
 func (bk *Bucket) PutNX(k, v []byte) error {
-	v, err := bk.Get(k)
-	if v != nil || err != nil {
-		return err
+	_, err := bk.PutIfAbsent(k, v)
+	return err
+}
+
+// PutIfAbsent inserts value `v` with key `k` if the key doesn't exist,
+// reporting whether the value was inserted.  Checking for the key and
+// inserting the value happen as part of a single transaction.
+func (bk *Bucket) PutIfAbsent(k, v []byte) (inserted bool, err error) {
+	defer bk.observe("PutIfAbsent", k)(&err)
+	err = bk.update(func(b *recorder) error {
+		if b.Get(k) != nil {
+			return nil
+		}
+		inserted = true
+		return b.Put(k, v)
+	})
+	if err != nil {
+		return false, err
 	}
-	return bk.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).Put(k, v)
+	return inserted, nil
+}
+
+// CompareAndSwap sets the value for key `k` to `v` if its current value
+// equals `old`, reporting whether the value was set.  A nil `old` means
+// the key is expected not to exist.  Comparing and setting the value
+// happen as part of a single transaction.
+func (bk *Bucket) CompareAndSwap(k, old, v []byte) (swapped bool, err error) {
+	defer bk.observe("CompareAndSwap", k)(&err)
+	err = bk.update(func(b *recorder) error {
+		current := b.Get(k)
+		if (current == nil) != (old == nil) || !bytes.Equal(current, old) {
+			return nil
+		}
+		swapped = true
+		return b.Put(k, v)
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+// UpdateValue sets the value for key `k` to the value returned by `fn`,
+// which is passed the key's current value (nil if the key doesn't
+// exist).  Reading and setting the value happen as part of a single
+// transaction.  If `fn` returns an error, the transaction is rolled back
+// and UpdateValue returns the error.  (The name Update is already taken
+// by the method for working with the underlying bolt bucket.)
+func (bk *Bucket) UpdateValue(k []byte, fn func(old []byte) ([]byte, error)) (err error) {
+	defer bk.observe("UpdateValue", k)(&err)
+	return bk.update(func(b *recorder) error {
+		v, err := fn(b.Get(k))
+		if err != nil {
+			return err
+		}
+		return b.Put(k, v)
+	})
+}
+
+// UpdatePrefix sets the value for each key with prefix `pre` to the value
+// returned by `transform`, which is passed the key and its current value.
+// All keys are updated as part of a single transaction, returning the
+// number of keys updated.  If `transform` returns an error, the
+// transaction is rolled back and UpdatePrefix returns the error.
+func (bk *Bucket) UpdatePrefix(pre []byte, transform func(k, v []byte) ([]byte, error)) (count int, err error) {
+	defer bk.observe("UpdatePrefix", pre)(&err)
+	err = bk.update(func(b *recorder) error {
+		// Collect the matching items before updating any of them, since
+		// writing under a bolt cursor can cause it to skip keys.
+		var items []Item
+		c := bk.cursor(b.Tx())
+		for k, v := c.Seek(pre); hasPrefix(k, pre); k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			value, err := bk.value(v)
+			if err != nil {
+				return err
+			}
+			key := make([]byte, len(k))
+			copy(key, k)
+			items = append(items, Item{key, value})
+		}
+		for _, item := range items {
+			v, err := transform(item.Key, item.Value)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(item.Key, v); err != nil {
+				return err
+			}
+		}
+		count = len(items)
+		return nil
 	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
 // Insert iterates over a slice of k/v pairs, putting each item in
 // the bucket as part of a single transaction.  For large insertions,
 // be sure to pre-sort your items (by Key in byte-sorted order), which
 // will result in much more efficient insertion times and storage costs.
-func (bk *Bucket) Insert(items []struct{ Key, Value []byte }) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
+// If any of the items can't be put (e.g., because the bucket is full;
+// see WithMaxKeys), none of them are, and Insert returns the error.
+func (bk *Bucket) Insert(items []struct{ Key, Value []byte }) (err error) {
+	defer bk.observe("Insert", nil)(&err)
+	return bk.update(func(b *recorder) error {
 		for _, item := range items {
-			tx.Bucket(bk.Name).Put(item.Key, item.Value)
+			if err := b.Put(item.Key, item.Value); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -98,71 +493,911 @@ func (bk *Bucket) Insert(items []struct{ Key, Value []byte }) error {
 // InsertNX (insert-if-not-exists) iterates over a slice of k/v pairs,
 // putting each item in the bucket as part of a single transaction.
 // Unlike Insert, however, InsertNX will not update the value for an
-// existing key.
-func (bk *Bucket) InsertNX(items []struct{ Key, Value []byte }) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
+// existing key.  Like Insert, if any of the items can't be put, none of
+// them are.
+func (bk *Bucket) InsertNX(items []struct{ Key, Value []byte }) (err error) {
+	defer bk.observe("InsertNX", nil)(&err)
+	return bk.update(func(b *recorder) error {
 		for _, item := range items {
-			v, _ := bk.Get(item.Key)
+			if v := b.Get(item.Key); v != nil {
+				continue
+			}
+			if err := b.Put(item.Key, item.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PutBatch puts each item in the bucket as part of a single
+// transaction, so that either all of the items are saved or none are.
+func (bk *Bucket) PutBatch(items []Item) (err error) {
+	defer bk.observe("PutBatch", nil)(&err)
+	return bk.update(func(b *recorder) error {
+		for _, item := range items {
+			if err := b.Put(item.Key, item.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PutBatchN puts each item in the bucket, splitting the items into
+// transactions of at most `size` items each.  Unlike PutBatch, the
+// items are not saved atomically: if a transaction fails, the items
+// in earlier transactions remain saved.  A `size` of zero or less puts
+// all of the items in a single transaction.
+func (bk *Bucket) PutBatchN(items []Item, size int) error {
+	if size <= 0 {
+		return bk.PutBatch(items)
+	}
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		if err := bk.PutBatch(items[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeAll copies each k/v pair from bucket `src` into this bucket as part
+// of a single transaction.  Keys missing from this bucket are added.  For
+// keys already present, `resolve` is called with the key, the existing
+// value, and the incoming value, and the key is updated with the returned
+// value if it differs from the existing one.  Both buckets must belong to
+// the same database.  MergeAll returns the number of keys added and updated.
+func (bk *Bucket) MergeAll(src *Bucket, resolve func(k, existing, incoming []byte) []byte) (added, updated int, err error) {
+	defer bk.observe("MergeAll", nil)(&err)
+	err = bk.update(func(dst *recorder) error {
+		if dst.Bucket == nil || src.bucket(dst.Tx()) == nil {
+			return ErrBucketNotFound
+		}
+		c := src.cursor(dst.Tx())
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := src.decode(v)
+			if err != nil {
+				return err
+			}
+			existing := dst.Get(k)
+			if existing == nil {
+				if err := dst.Put(k, v); err != nil {
+					return err
+				}
+				added++
+				continue
+			}
+			resolved := resolve(k, existing, v)
+			if bytes.Equal(resolved, existing) {
+				continue
+			}
+			if err := dst.Put(k, resolved); err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return added, updated, nil
+}
+
+// MergeFrom copies each k/v pair from bucket `src` into this bucket as
+// part of a single transaction, overwriting the values of keys already
+// present.  Both buckets must belong to the same database.
+func (bk *Bucket) MergeFrom(src *Bucket) error {
+	return bk.MergeFromWithConflict(src, func(_, incoming []byte) []byte {
+		return incoming
+	})
+}
+
+// MergeFromWithConflict copies each k/v pair from bucket `src` into this
+// bucket like MergeFrom, but the value of a key already present is set to
+// the value returned by `resolve`, which is passed the existing value and
+// the incoming value.  It's shorthand for MergeAll, ignoring the counts.
+func (bk *Bucket) MergeFromWithConflict(src *Bucket, resolve func(existing, incoming []byte) []byte) error {
+	_, _, err := bk.MergeAll(src, func(_, existing, incoming []byte) []byte {
+		return resolve(existing, incoming)
+	})
+	return err
+}
+
+// CompactTo copies each k/v pair in the bucket to bucket `dst`, in key
+// order, as part of a single transaction.  Since the keys are put in
+// order into pages that are filled completely (unless `dst` was given
+// another Hint), the copy takes up as few pages as possible.  Expired
+// keys aren't copied, and tags and expiry times are kept, if `dst` can
+// hold them (see NewTagged and NewTTL).  Both buckets must belong to the
+// same database.  To compact a bucket in place, compact
+// it to a new bucket, delete it, and rename the new bucket (see
+// RenameBucket).
+func (bk *Bucket) CompactTo(dst *Bucket) (err error) {
+	defer bk.observe("CompactTo", nil)(&err)
+	if sameBucket(bk, dst) {
+		return fmt.Errorf("can't compact bucket %s into itself", bk.Name)
+	}
+	if dst.fill == 0 {
+		dst = dst.WithHint(HintSequential)
+	}
+	return dst.update(func(b *recorder) error {
+		if b.Bucket == nil || bk.bucket(b.Tx()) == nil {
+			return ErrBucketNotFound
+		}
+		c := bk.cursor(b.Tx())
+		for k, v := c.First(); k != nil; k, v = c.Next() {
 			if v == nil {
-				tx.Bucket(bk.Name).Put(item.Key, item.Value)
+				continue
+			}
+			value, err := bk.unpack(v)
+			if err != nil {
+				return err
+			}
+			var header []byte
+			if bk.tagged {
+				if header, value, err = splitTags(value); err != nil {
+					return err
+				}
+			}
+			if err := b.store(k, value, header, bk.expiry(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Shard distributes the items in the bucket across `n` shard buckets,
+// putting each item in `shards[hashKey(k) % n]`.  Each shard is written
+// in its own transaction.  Once all the shards are written, the sharded
+// items are removed from the bucket, leaving it empty.  The bucket can't
+// be one of its own shards.
+func (bk *Bucket) Shard(n int, shards []*Bucket) (err error) {
+	defer bk.observe("Shard", nil)(&err)
+	if n < 1 || n != len(shards) {
+		return fmt.Errorf("can't shard %s into %d buckets: got %d shards",
+			bk.Name, n, len(shards))
+	}
+	for _, shard := range shards {
+		if sameBucket(bk, shard) {
+			return fmt.Errorf("can't shard bucket %s into itself", bk.Name)
+		}
+	}
+	items, err := bk.Items()
+	if err != nil {
+		return err
+	}
+	groups := make([][]Item, n)
+	for _, item := range items {
+		i := hashKey(item.Key) % uint32(n)
+		groups[i] = append(groups[i], item)
+	}
+	for i, shard := range shards {
+		err := shard.update(func(b *recorder) error {
+			for _, item := range groups[i] {
+				if err := b.Put(item.Key, item.Value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return bk.update(func(b *recorder) error {
+		for _, item := range items {
+			if err := b.Delete(item.Key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes key `k`.  Deleting a key that doesn't exist is not
+// an error.
+func (bk *Bucket) Delete(k []byte) (err error) {
+	defer bk.observe("Delete", k)(&err)
+	return bk.update(func(b *recorder) error {
+		return b.Delete(k)
+	})
+}
+
+// DeleteBatch removes keys `keys` as part of a single transaction,
+// returning the number of keys removed.  Keys that don't exist are
+// skipped.
+func (bk *Bucket) DeleteBatch(keys [][]byte) (count int, err error) {
+	defer bk.observe("DeleteBatch", nil)(&err)
+	err = bk.update(func(b *recorder) error {
+		count = 0
+		for _, k := range keys {
+			if bk.get(b.Tx(), k) == nil {
+				continue
+			}
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeletePrefix removes all keys with prefix `pre` as part of a single
+// transaction, returning the number of keys removed.
+func (bk *Bucket) DeletePrefix(pre []byte) (count int, err error) {
+	defer bk.observe("DeletePrefix", pre)(&err)
+	err = bk.update(func(b *recorder) error {
+		// Collect the matching keys before deleting any of them, since
+		// deleting under a bolt cursor can cause it to skip keys.
+		var keys [][]byte
+		c := b.Cursor()
+		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
+			if v != nil {
+				key := make([]byte, len(k))
+				copy(key, k)
+				keys = append(keys, key)
+			}
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		count = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteRange removes all keys within the range `min` to `max`
+// (inclusive) as part of a single transaction, returning the number of
+// keys removed.  A nil `min` or `max` leaves that end of the range open.
+func (bk *Bucket) DeleteRange(min, max []byte) (count int, err error) {
+	defer bk.observe("DeleteRange", nil)(&err)
+	err = bk.update(func(b *recorder) error {
+		// Collect the matching keys before deleting any of them, since
+		// deleting under a bolt cursor can cause it to skip keys.
+		var keys [][]byte
+		c := b.Cursor()
+		for k, v := c.Seek(min); isBefore(k, max); k, v = c.Next() {
+			if v != nil {
+				key := make([]byte, len(k))
+				copy(key, k)
+				keys = append(keys, key)
 			}
 		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		count = len(keys)
 		return nil
 	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
-// Delete removes key `k`.
-func (bk *Bucket) Delete(k []byte) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).Delete(k)
+// Clear removes all keys from the bucket, returning the number of keys
+// removed.  Rather than deleting the keys one by one, Clear deletes and
+// recreates the underlying bolt bucket as part of a single transaction,
+// so any buckets nested within the bucket are removed too.  Note that
+// recreating the bucket resets its sequence (see NextSequence) to zero,
+// though its persistent settings (e.g., whether its keys can expire) are
+// kept.
+func (bk *Bucket) Clear() (count int, err error) {
+	defer bk.observe("Clear", nil)(&err)
+	err = bk.update(func(b *recorder) error {
+		if b.Bucket == nil {
+			return ErrBucketNotFound
+		}
+		// Keys are only collected if their removal must be recorded or
+		// applied to the bucket's secondary indexes.
+		collect := b.record || len(b.indexes) > 0
+		var keys [][]byte
+		c := bk.cursor(b.Tx())
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			count++
+			if collect {
+				key := make([]byte, len(k))
+				copy(key, k)
+				keys = append(keys, key)
+			}
+		}
+		for _, k := range keys {
+			if err := b.reindex(k, b.indexKeys(k), nil); err != nil {
+				return err
+			}
+			b.add(OpDelete, k, nil)
+		}
+		nb, err := bk.recreate(b.Tx())
+		if err != nil {
+			return err
+		}
+		if err := bk.saveSettings(nb); err != nil {
+			return err
+		}
+		if bk.fill != 0 {
+			nb.FillPercent = bk.fill
+		}
+		b.Bucket = nb
+		return nil
 	})
+	if err != nil {
+		return 0, err
+	}
+	if bk.warm != nil {
+		bk.warm.Range(func(k, _ interface{}) bool {
+			bk.warm.Delete(k)
+			return true
+		})
+	}
+	return count, nil
+}
+
+// recreate deletes the bolt bucket for bk within transaction `tx` and
+// creates an empty one in its place.
+func (bk *Bucket) recreate(tx *bolt.Tx) (*bolt.Bucket, error) {
+	if bk.parent == nil {
+		if err := tx.DeleteBucket(bk.Name); err != nil {
+			return nil, err
+		}
+		return tx.CreateBucket(bk.Name)
+	}
+	parent := bk.parent.bucket(tx)
+	if parent == nil {
+		return nil, ErrBucketNotFound
+	}
+	if err := parent.DeleteBucket(bk.Name); err != nil {
+		return nil, err
+	}
+	return parent.CreateBucket(bk.Name)
 }
 
-// Get retrieves the value for key `k`.
+// BulkDelete removes `keys`, splitting them among `workers` goroutines
+// that each remove their share of the keys in a separate transaction.
+// Since bolt only runs one read-write transaction at a time, this doesn't
+// remove keys in parallel, but it does spread the cost of each transaction
+// over many keys.  If any of the transactions fail, BulkDelete returns
+// one of the errors, but keys removed by other transactions stay removed.
+// For a bucket opened within a Tx, the keys are removed by a single
+// goroutine as part of the Tx.
+func (bk *Bucket) BulkDelete(keys [][]byte, workers int) (err error) {
+	defer bk.observe("BulkDelete", nil)(&err)
+	if len(keys) == 0 {
+		return nil
+	}
+	if workers < 1 || bk.tx != nil {
+		workers = 1
+	}
+	if workers > len(keys) {
+		workers = len(keys)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	size := (len(keys) + workers - 1) / workers
+	for start := 0; start < len(keys); start += size {
+		end := start + size
+		if end > len(keys) {
+			end = len(keys)
+		}
+		wg.Add(1)
+		go func(keys [][]byte) {
+			defer wg.Done()
+			errs <- bk.update(func(b *recorder) error {
+				for _, k := range keys {
+					if err := b.Delete(k); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}(keys[start:end])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get retrieves the value for key `k`.  The returned value is a copy,
+// so it's safe to use after the transaction.  If the key doesn't exist,
+// Get returns a nil value and a nil error.
 func (bk *Bucket) Get(k []byte) (value []byte, err error) {
-	err = bk.db.View(func(tx *bolt.Tx) error {
-		v := tx.Bucket(bk.Name).Get(k)
+	defer bk.observe("Get", k)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		v := bk.get(tx, k)
 		if v != nil {
-			value = make([]byte, len(v))
-			copy(value, v)
+			value, err = bk.value(v)
 		}
-		return nil
+		return err
 	})
 	return value, err
 }
 
+// GetBatch retrieves the values for `keys` as part of a single
+// transaction.  The returned items are in the same order as `keys`.  The
+// Value of an item is nil if its key doesn't exist.
+func (bk *Bucket) GetBatch(keys [][]byte) (items []Item, err error) {
+	defer bk.observe("GetBatch", nil)(&err)
+	items = make([]Item, len(keys))
+	err = bk.view(func(tx *bolt.Tx) error {
+		for i, k := range keys {
+			items[i].Key = k
+			if v := bk.get(tx, k); v != nil {
+				value, err := bk.value(v)
+				if err != nil {
+					return err
+				}
+				items[i].Value = value
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ItemsByKeys retrieves the items for `keys` as part of a single
+// transaction.  The returned items are in the same order as `keys`, like
+// GetBatch, but the item for a key that doesn't exist is nil.
+func (bk *Bucket) ItemsByKeys(keys [][]byte) ([]*Item, error) {
+	batch, err := bk.GetBatch(keys)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*Item, len(batch))
+	for i := range batch {
+		if batch[i].Value != nil {
+			items[i] = &batch[i]
+		}
+	}
+	return items, nil
+}
+
+// GetMulti retrieves the values for `keys` as part of a single
+// transaction, returning a mapping of each existing key to its value.
+// Keys that don't exist are omitted from the mapping.
+func (bk *Bucket) GetMulti(keys [][]byte) (map[string][]byte, error) {
+	items, err := bk.GetBatch(keys)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(items))
+	for _, item := range items {
+		if item.Value != nil {
+			values[string(item.Key)] = item.Value
+		}
+	}
+	return values, nil
+}
+
+// Has reports whether key `k` exists.  Unlike Get, it doesn't copy
+// the value out of the transaction.  A key with an empty value exists.
+func (bk *Bucket) Has(k []byte) (exists bool, err error) {
+	defer bk.observe("Has", k)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		exists = bk.get(tx, k) != nil
+		return nil
+	})
+	return exists, err
+}
+
+// SizeOf returns the length of the value for key `k`, without copying
+// the value out of the transaction.  If the key doesn't exist, SizeOf
+// returns ErrKeyNotFound.
+func (bk *Bucket) SizeOf(k []byte) (size int, err error) {
+	defer bk.observe("SizeOf", k)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		v := bk.get(tx, k)
+		if v == nil {
+			return ErrKeyNotFound
+		}
+		v, err := bk.decode(v)
+		if err != nil {
+			return err
+		}
+		size = len(v)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Exists reports whether key `k` exists, by seeking a cursor to the
+// key rather than getting its value.  Like Has, it distinguishes a
+// missing key from a key with an empty value.  If the bucket has been
+// warmed up (see WarmUp), keys known to exist are found without a
+// transaction.
+func (bk *Bucket) Exists(k []byte) (exists bool, err error) {
+	defer bk.observe("Exists", k)(&err)
+	if bk.warm != nil && !bk.ttl {
+		if _, ok := bk.warm.Load(string(k)); ok {
+			return true, nil
+		}
+	}
+	err = bk.view(func(tx *bolt.Tx) error {
+		key, v := bk.cursor(tx).Seek(k)
+		exists = v != nil && bytes.Equal(key, k)
+		return nil
+	})
+	return exists, err
+}
+
+// ValueAt retrieves the value of the `n`th key (counting from zero) in
+// byte-sorted order, by stepping a cursor past the preceding keys rather
+// than copying them out of the transaction.  If there are `n` keys or
+// fewer, ValueAt returns ErrOutOfRange.
+func (bk *Bucket) ValueAt(n int) (value []byte, err error) {
+	defer bk.observe("ValueAt", nil)(&err)
+	if n < 0 {
+		return nil, ErrOutOfRange
+	}
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		i := 0
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			if i == n {
+				value, err = bk.value(v)
+				return err
+			}
+			i++
+		}
+		return ErrOutOfRange
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// First returns the first key in the bucket, in byte-sorted order, and
+// its value.  If the bucket is empty, First returns nil, nil, nil.
+func (bk *Bucket) First() (key, value []byte, err error) {
+	defer bk.observe("First", nil)(&err)
+	return bk.end(false)
+}
+
+// Last returns the last key in the bucket, in byte-sorted order, and its
+// value.  If the bucket is empty, Last returns nil, nil, nil.
+func (bk *Bucket) Last() (key, value []byte, err error) {
+	defer bk.observe("Last", nil)(&err)
+	return bk.end(true)
+}
+
+// end returns a copy of the first key/value pair in the bucket, or of
+// the last if `last` is true.  Nested buckets are skipped.
+func (bk *Bucket) end(last bool) (key, value []byte, err error) {
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		k, v := c.First()
+		next := c.Next
+		if last {
+			k, v = c.Last()
+			next = c.Prev
+		}
+		for ; k != nil; k, v = next() {
+			if v == nil {
+				continue
+			}
+			key = make([]byte, len(k))
+			copy(key, k)
+			value, err = bk.value(v)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, value, nil
+}
+
+// Count returns a count of the keys in the bucket.  It doesn't copy
+// any keys or values out of the transaction.
+func (bk *Bucket) Count() (count int, err error) {
+	defer bk.observe("Count", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		count = bk.count(tx)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// count returns a count of the keys in the bucket within transaction
+// `tx`.
+func (bk *Bucket) count(tx *bolt.Tx) (count int) {
+	c := bk.cursor(tx)
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// PrefixCount returns a count of the keys with prefix `pre`.  Like
+// Count, it doesn't copy any keys or values out of the transaction.
+func (bk *Bucket) PrefixCount(pre []byte) (count int, err error) {
+	defer bk.observe("PrefixCount", pre)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.Seek(pre); hasPrefix(k, pre); k, v = c.Next() {
+			if v != nil {
+				count++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// KeyPrefix returns the longest prefix shared by all keys in the bucket.
+// Since keys are byte-sorted, this is the prefix shared by the first and
+// last keys.  A bucket with a single key returns the key itself, and an
+// empty bucket returns nil.
+func (bk *Bucket) KeyPrefix() (prefix []byte, err error) {
+	defer bk.observe("KeyPrefix", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		first, v := c.First()
+		for first != nil && v == nil {
+			first, v = c.Next()
+		}
+		if first == nil {
+			return nil
+		}
+		last, v := c.Last()
+		for last != nil && v == nil {
+			last, v = c.Prev()
+		}
+		pre := commonPrefix(first, last)
+		prefix = make([]byte, len(pre))
+		copy(prefix, pre)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prefix, nil
+}
+
 // Items returns a slice of key/value pairs.  Each k/v pair in the slice
 // is of type Item (`struct{ Key, Value []byte }`).
 func (bk *Bucket) Items() (items []Item, err error) {
-	return items, bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	defer bk.observe("Items", nil)(&err)
+	return items, bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
 		var key, value []byte
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			if v != nil {
 				key = make([]byte, len(k))
 				copy(key, k)
-				value = make([]byte, len(v))
-				copy(value, v)
+				if value, err = bk.value(v); err != nil {
+					return err
+				}
+				items = append(items, Item{key, value})
+			}
+		}
+		return nil
+	})
+}
+
+// Slice returns the key/value pairs from position `start` up to, but not
+// including, position `end`, in byte-sorted order.  As with Python
+// slices, a negative position counts back from the end of the bucket and
+// positions past either end are clamped.  For negative positions, the
+// keys are counted first.
+func (bk *Bucket) Slice(start, end int) (items []Item, err error) {
+	defer bk.observe("Slice", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		if start < 0 || end < 0 {
+			n := 0
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				if v != nil {
+					n++
+				}
+			}
+			if start < 0 {
+				start += n
+			}
+			if end < 0 {
+				end += n
+			}
+		}
+		i := 0
+		for k, v := c.First(); k != nil && i < end; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			if i >= start {
+				key := make([]byte, len(k))
+				copy(key, k)
+				value, err := bk.value(v)
+				if err != nil {
+					return err
+				}
 				items = append(items, Item{key, value})
 			}
+			i++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// SortedKeys returns a slice of all keys in the bucket, sorted with
+// `less` rather than in bolt's byte-sorted order.
+func (bk *Bucket) SortedKeys(less func(a, b []byte) bool) (keys [][]byte, err error) {
+	defer bk.observe("SortedKeys", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v != nil {
+				key := make([]byte, len(k))
+				copy(key, k)
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return less(keys[i], keys[j])
+	})
+	return keys, nil
+}
+
+// MatchKeys returns a slice of the keys that match shell glob `pattern`,
+// as interpreted by path.Match.  For example, "/mon/*" matches "/mon/9am"
+// but not "/mon/9am/standup".  Every key is checked, but no values are
+// read.  If the pattern is malformed, MatchKeys returns path.ErrBadPattern.
+func (bk *Bucket) MatchKeys(pattern string) (keys [][]byte, err error) {
+	defer bk.observe("MatchKeys", nil)(&err)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			if ok, _ := path.Match(pattern, string(k)); ok {
+				key := make([]byte, len(k))
+				copy(key, k)
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// KeysAfter returns a slice of at most `limit` keys that come after `key`,
+// in byte-sorted order, without reading any values.  A limit of zero
+// means no limit.  For keyset pagination, pass the last key of one page
+// to get the keys of the next.
+func (bk *Bucket) KeysAfter(key []byte, limit int) (keys [][]byte, err error) {
+	defer bk.observe("KeysAfter", key)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		k, v := c.Seek(key)
+		if k != nil && bytes.Equal(k, key) {
+			k, v = c.Next()
+		}
+		for ; k != nil; k, v = c.Next() {
+			if limit > 0 && len(keys) >= limit {
+				break
+			}
+			if v == nil {
+				continue
+			}
+			kc := make([]byte, len(k))
+			copy(kc, k)
+			keys = append(keys, kc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SelectValues applies `transform` on each key/value pair, returning a
+// slice of the transformed values for which `transform` also returns
+// true.  The key and value passed to `transform` are only valid while
+// it runs, so the transformed values are copied before being returned.
+func (bk *Bucket) SelectValues(transform func(k, v []byte) ([]byte, bool)) (values [][]byte, err error) {
+	defer bk.observe("SelectValues", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
+			if selected, ok := transform(k, v); ok {
+				value := make([]byte, len(selected))
+				copy(value, selected)
+				values = append(values, value)
+			}
 		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
 }
 
 // PrefixItems returns a slice of key/value pairs for all keys with
 // a given prefix.  Each k/v pair in the slice is of type Item
 // (`struct{ Key, Value []byte }`).
 func (bk *Bucket) PrefixItems(pre []byte) (items []Item, err error) {
-	err = bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	defer bk.observe("PrefixItems", pre)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
 		var key, value []byte
 		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
 			if v != nil {
 				key = make([]byte, len(k))
 				copy(key, k)
-				value = make([]byte, len(v))
-				copy(value, v)
+				if value, err = bk.value(v); err != nil {
+					return err
+				}
 				items = append(items, Item{key, value})
 			}
 		}
@@ -171,19 +1406,52 @@ func (bk *Bucket) PrefixItems(pre []byte) (items []Item, err error) {
 	return items, err
 }
 
+// FilterPrefix returns a slice of key/value pairs for keys with prefix
+// `pre` for which `fn` returns true.  The key and value passed to `fn`
+// are only valid while it runs; the returned pairs are copies.
+func (bk *Bucket) FilterPrefix(pre []byte, fn func(k, v []byte) bool) (items []Item, err error) {
+	defer bk.observe("FilterPrefix", pre)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.Seek(pre); hasPrefix(k, pre); k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
+			if !fn(k, v) {
+				continue
+			}
+			item := Item{make([]byte, len(k)), make([]byte, len(v))}
+			copy(item.Key, k)
+			copy(item.Value, v)
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 // RangeItems returns a slice of key/value pairs for all keys within
 // a given range.  Each k/v pair in the slice is of type Item
 // (`struct{ Key, Value []byte }`).
 func (bk *Bucket) RangeItems(min []byte, max []byte) (items []Item, err error) {
-	err = bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	defer bk.observe("RangeItems", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
 		var key, value []byte
 		for k, v := c.Seek(min); isBefore(k, max); k, v = c.Next() {
 			if v != nil {
 				key = make([]byte, len(k))
 				copy(key, k)
-				value = make([]byte, len(v))
-				copy(value, v)
+				if value, err = bk.value(v); err != nil {
+					return err
+				}
 				items = append(items, Item{key, value})
 			}
 		}
@@ -193,17 +1461,87 @@ func (bk *Bucket) RangeItems(min []byte, max []byte) (items []Item, err error) {
 }
 
 // Map applies `do` on each key/value pair.
-func (bk *Bucket) Map(do func(k, v []byte) error) error {
-	return bk.db.View(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).ForEach(do)
+func (bk *Bucket) Map(do func(k, v []byte) error) (err error) {
+	defer bk.observe("Map", nil)(&err)
+	return bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
+			if err := do(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ForEach applies `do` on each key/value pair, stopping at the first
+// error returned by `do`.  If that error is ErrStop, ForEach returns nil;
+// otherwise, it returns the error.  The key and value passed to `do`
+// are only valid while it runs.
+func (bk *Bucket) ForEach(do func(k, v []byte) error) (err error) {
+	defer bk.observe("ForEach", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
+			if err := do(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrStop {
+		return nil
+	}
+	return err
+}
+
+// MapAll applies `do` on each key/value pair, like ForEach, also passing
+// the position of the pair (counting from zero).  If `do` returns ErrStop,
+// MapAll stops and returns nil; if it returns any other error, MapAll
+// stops and returns the error.
+func (bk *Bucket) MapAll(do func(i int, k, v []byte) error) error {
+	i := 0
+	return bk.ForEach(func(k, v []byte) error {
+		err := do(i, k, v)
+		i++
+		return err
 	})
 }
 
+// ForRange applies `do` on each key/value pair for keys from `from`
+// through `to`, like ForEach.  As with NewRangeScanner, a nil `from` or
+// `to` leaves the range unbounded at that end.
+func (bk *Bucket) ForRange(from, to []byte, do func(k, v []byte) error) error {
+	return bk.NewRangeScanner(from, to).ForEach(do)
+}
+
+// ForPrefix applies `do` on each key/value pair for keys with prefix
+// `pre`, like ForEach.
+func (bk *Bucket) ForPrefix(pre []byte, do func(k, v []byte) error) error {
+	return bk.NewPrefixScanner(pre).ForEach(do)
+}
+
 // MapPrefix applies `do` on each k/v pair of keys with prefix.
-func (bk *Bucket) MapPrefix(do func(k, v []byte) error, pre []byte) error {
-	return bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+func (bk *Bucket) MapPrefix(do func(k, v []byte) error, pre []byte) (err error) {
+	defer bk.observe("MapPrefix", pre)(&err)
+	return bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
 		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
 			do(k, v)
 		}
 		return nil
@@ -211,10 +1549,15 @@ func (bk *Bucket) MapPrefix(do func(k, v []byte) error, pre []byte) error {
 }
 
 // MapRange applies `do` on each k/v pair of keys within range.
-func (bk *Bucket) MapRange(do func(k, v []byte) error, min, max []byte) error {
-	return bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+func (bk *Bucket) MapRange(do func(k, v []byte) error, min, max []byte) (err error) {
+	defer bk.observe("MapRange", nil)(&err)
+	return bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
 		for k, v := c.Seek(min); isBefore(k, max); k, v = c.Next() {
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
 			do(k, v)
 		}
 		return nil
@@ -223,11 +1566,19 @@ func (bk *Bucket) MapRange(do func(k, v []byte) error, min, max []byte) error {
 
 // NewPrefixScanner initializes a new prefix scanner.
 func (bk *Bucket) NewPrefixScanner(pre []byte) *PrefixScanner {
-	return &PrefixScanner{bk.db, bk.Name, pre}
+	return &PrefixScanner{bk: bk, BucketName: bk.Name, Prefix: pre}
+}
+
+// Seek initializes a new scanner over all keys in the bucket, starting at
+// key `start`.  It's shorthand for NewPrefixScanner(nil).SeekFrom(start).
+func (bk *Bucket) Seek(start []byte) *PrefixScanner {
+	return bk.NewPrefixScanner(nil).SeekFrom(start)
 }
 
 // NewRangeScanner initializes a new range scanner.  It takes a `min` and a
-// `max` key for specifying the range paramaters.
+// `max` key for specifying the range paramaters.  A nil `min` starts the
+// scan at the first key in the bucket and a nil `max` runs it through the
+// last key.  If `min` comes after `max`, the scan is empty.
 func (bk *Bucket) NewRangeScanner(min, max []byte) *RangeScanner {
-	return &RangeScanner{bk.db, bk.Name, min, max}
+	return &RangeScanner{bk: bk, BucketName: bk.Name, Min: min, Max: max}
 }
@@ -0,0 +1,77 @@
+package buckets
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// A Codec encodes and decodes the values put and got by PutValue and
+// GetValue.
+type Codec struct {
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON.  It's the default codec.
+var JSONCodec = Codec{Marshal: json.Marshal, Unmarshal: json.Unmarshal}
+
+// GobCodec encodes values with encoding/gob.  Each value is encoded as a
+// self-contained gob stream, including its type description.
+var GobCodec = Codec{Marshal: gobMarshal, Unmarshal: gobUnmarshal}
+
+// gobMarshal returns the gob encoding of `v`.
+func gobMarshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gobUnmarshal decodes gob-encoded `data` into `v`.
+func gobUnmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// WithCodec returns a copy of the bucket whose PutValue and GetValue
+// methods use codec `c`.  Other methods, including Put and Get, read and
+// write values as is.
+func (bk *Bucket) WithCodec(c Codec) *Bucket {
+	bucket := *bk
+	bucket.codec = c
+	return &bucket
+}
+
+// PutValue inserts the encoding of `v` with key `k`, using the bucket's
+// codec (JSONCodec, unless set with WithCodec).
+func (bk *Bucket) PutValue(k []byte, v interface{}) error {
+	value, err := bk.codecOrDefault().Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bk.Put(k, value)
+}
+
+// GetValue retrieves the value for key `k` and decodes it into `dst`,
+// using the bucket's codec.  If the key doesn't exist, GetValue returns
+// ErrKeyNotFound; if the value can't be decoded, it returns the decoding
+// error.
+func (bk *Bucket) GetValue(k []byte, dst interface{}) error {
+	value, err := bk.Get(k)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return ErrKeyNotFound
+	}
+	return bk.codecOrDefault().Unmarshal(value, dst)
+}
+
+// codecOrDefault returns the bucket's codec, or JSONCodec if none is set.
+func (bk *Bucket) codecOrDefault() Codec {
+	if bk.codec.Marshal == nil || bk.codec.Unmarshal == nil {
+		return JSONCodec
+	}
+	return bk.codec
+}
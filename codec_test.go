@@ -0,0 +1,69 @@
+package buckets_test
+
+import (
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can put and get values with the default and built-in codecs.
+func TestCodec(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	type Todo struct {
+		Task string
+		Done bool
+	}
+	want := Todo{"milk cows", true}
+
+	// JSON is the default codec.
+	if err := todos.PutValue([]byte("1"), want); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := todos.Get([]byte("1")); string(v) != `{"Task":"milk cows","Done":true}` {
+		t.Errorf("got %s, want JSON encoding", v)
+	}
+
+	gobTodos := todos.WithCodec(buckets.GobCodec)
+	if err := gobTodos.PutValue([]byte("2"), want); err != nil {
+		t.Error(err.Error())
+	}
+	for _, tt := range []struct {
+		bk  *buckets.Bucket
+		key string
+	}{
+		{todos, "1"},
+		{gobTodos, "2"},
+	} {
+		var got Todo
+		if err := tt.bk.GetValue([]byte(tt.key), &got); err != nil {
+			t.Error(err.Error())
+		}
+		if got != want {
+			t.Errorf("key %s: got %+v, want %+v", tt.key, got, want)
+		}
+	}
+
+	// Values put with one codec can't be got with another.
+	var got Todo
+	if err := todos.GetValue([]byte("2"), &got); err == nil {
+		t.Error("expected error decoding gob value as JSON")
+	}
+	if err := gobTodos.GetValue([]byte("3"), &got); err != buckets.ErrKeyNotFound {
+		t.Errorf("got %v, want ErrKeyNotFound", err)
+	}
+
+	// Put and Get bypass the codec.
+	if err := gobTodos.Put([]byte("4"), []byte("raw")); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := gobTodos.Get([]byte("4")); string(v) != "raw" {
+		t.Errorf("got %q, want raw", v)
+	}
+}
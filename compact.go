@@ -0,0 +1,131 @@
+package buckets
+
+import (
+	"os"
+
+	"github.com/boltdb/bolt"
+)
+
+// compactTxSize is the number of keys Compact writes per transaction.
+const compactTxSize = 10000
+
+// Compact copies every bucket, nested bucket, and key in the database to
+// a new database file at `dstPath`, returning the size of the new file.
+// Since keys are copied in order into pages that are filled completely,
+// the new file is typically much smaller than the original after many
+// keys have been deleted.  The keys are read within a single read-only
+// transaction, so the copy is a consistent snapshot, but written in
+// transactions of a bounded number of keys, so compacting a large
+// database doesn't build up one huge transaction.  The original file is
+// left as is.  Compact won't overwrite an existing file, and if the copy
+// fails, the new file is removed.
+func (db *DB) Compact(dstPath string) (size int64, err error) {
+	f, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, err
+	}
+	f.Close()
+	dst, err := bolt.Open(dstPath, 0600, nil)
+	if err != nil {
+		os.Remove(dstPath)
+		return 0, err
+	}
+	c := &compactor{dst: dst}
+	err = db.DB.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return c.walk([][]byte{name}, b)
+		})
+	})
+	if err == nil {
+		err = c.commit()
+	} else if c.tx != nil {
+		c.tx.Rollback()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dstPath)
+		return 0, err
+	}
+	fi, err := os.Stat(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// A compactor writes the buckets and keys copied by Compact, committing
+// a transaction after every compactTxSize keys.
+type compactor struct {
+	dst *bolt.DB
+	tx  *bolt.Tx // current transaction, if any
+	n   int      // keys written in the current transaction
+}
+
+// walk copies bolt bucket `b`, nested within the buckets named by `path`,
+// and the buckets nested within it.
+func (c *compactor) walk(path [][]byte, b *bolt.Bucket) error {
+	dst, err := c.bucket(path)
+	if err != nil {
+		return err
+	}
+	if err := dst.SetSequence(b.Sequence()); err != nil {
+		return err
+	}
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return c.walk(append(path[:len(path):len(path)], k), b.Bucket(k))
+		}
+		return c.put(path, k, v)
+	})
+}
+
+// put writes key `k` with value `v` to the bucket named by `path`.
+func (c *compactor) put(path [][]byte, k, v []byte) error {
+	if c.n >= compactTxSize {
+		if err := c.commit(); err != nil {
+			return err
+		}
+	}
+	dst, err := c.bucket(path)
+	if err != nil {
+		return err
+	}
+	c.n++
+	return dst.Put(k, v)
+}
+
+// bucket returns the bucket named by `path` within the current
+// transaction, beginning one if needed and creating the bucket if it
+// doesn't exist.
+func (c *compactor) bucket(path [][]byte) (*bolt.Bucket, error) {
+	if c.tx == nil {
+		tx, err := c.dst.Begin(true)
+		if err != nil {
+			return nil, err
+		}
+		c.tx = tx
+	}
+	b, err := c.tx.CreateBucketIfNotExists(path[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range path[1:] {
+		if b, err = b.CreateBucketIfNotExists(name); err != nil {
+			return nil, err
+		}
+	}
+	b.FillPercent = 1.0
+	return b, nil
+}
+
+// commit commits the current transaction, if any.
+func (c *compactor) commit() error {
+	if c.tx == nil {
+		return nil
+	}
+	err := c.tx.Commit()
+	c.tx, c.n = nil, 0
+	return err
+}
@@ -0,0 +1,86 @@
+package buckets_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure compacting a db copies all buckets and keys to a smaller file.
+func TestCompact(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	nested, err := things.NewChild([]byte("nested"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := nested.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	items := make([]buckets.Item, 30000)
+	for i := range items {
+		items[i] = buckets.NewItem([]byte(fmt.Sprintf("key%05d", i)), []byte("value"))
+	}
+	if err := things.PutBatch(items); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := things.DeletePrefix([]byte("key0")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := things.NextSequence(); err != nil {
+		t.Error(err.Error())
+	}
+
+	path := tempfile()
+	defer os.Remove(path)
+	size, err := bx.Compact(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	fi, err := os.Stat(bx.Path())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if size >= fi.Size() {
+		t.Errorf("got compacted size %d, want less than %d", size, fi.Size())
+	}
+
+	compacted, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer compacted.Close()
+
+	copied, err := compacted.Bucket([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n, _ := copied.Count(); n != 20000 {
+		t.Errorf("got %d keys, want 20000", n)
+	}
+	if v, _ := copied.Get([]byte("key29999")); string(v) != "value" {
+		t.Errorf("got %q, want value", v)
+	}
+	child, err := copied.NewChild([]byte("nested"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := child.Get([]byte("A")); string(v) != "alpha" {
+		t.Errorf("got %q for nested key, want alpha", v)
+	}
+	if seq, _ := copied.NextSequence(); seq != 2 {
+		t.Errorf("got sequence %d, want 2", seq)
+	}
+
+	// An existing file isn't overwritten.
+	if _, err := bx.Compact(path); !os.IsExist(err) {
+		t.Errorf("got %v, want file exists error", err)
+	}
+}
@@ -0,0 +1,90 @@
+package buckets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// compressedMagic is the header prepended to compressed values, so that
+// values stored before compression was enabled can still be read.
+var compressedMagic = []byte("\x00bkz")
+
+// compressedSetting is the settings key present in buckets opened with
+// NewCompressed.
+var compressedSetting = []byte("compressed")
+
+// NewCompressed creates/opens a named bucket whose values are gzip
+// compressed when stored and transparently decompressed when read.  Keys
+// are stored as is, so prefix and range scans work as usual.  Values
+// stored without compression (e.g., before the bucket was opened with
+// NewCompressed) are read back as is.
+//
+// Like NewTTL, whether a bucket's values are compressed is stored in the
+// database, so the bucket works the same way when opened with New or
+// Bucket.  Unlike NewTTL, an existing bucket can be made a compressed
+// bucket while it holds keys.
+func (db *DB) NewCompressed(name []byte) (*Bucket, error) {
+	return db.newWithSetting(name, compressedSetting, true)
+}
+
+// encode returns value `v` as it should be stored in the bucket.  If the
+// bucket's keys can expire, the stored value is prefixed with `expiry`.
+func (bk *Bucket) encode(v []byte, expiry int64) ([]byte, error) {
+	if bk.compressed && v != nil {
+		var buf bytes.Buffer
+		buf.Write(compressedMagic)
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(v); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		v = buf.Bytes()
+	}
+	if bk.ttl {
+		v = withExpiry(v, expiry)
+	}
+	return v, nil
+}
+
+// decode returns the original value of stored value `v`, without any
+// tags.  The decoded value may share memory with `v`.
+func (bk *Bucket) decode(v []byte) ([]byte, error) {
+	v, err := bk.unpack(v)
+	if err != nil || !bk.tagged {
+		return v, err
+	}
+	_, v, err = splitTags(v)
+	return v, err
+}
+
+// unpack returns stored value `v` as it was put, with any tags.  The
+// unpacked value may share memory with `v`.
+func (bk *Bucket) unpack(v []byte) ([]byte, error) {
+	if bk.ttl && len(v) >= expirySize {
+		v = v[expirySize:]
+	}
+	if !bk.compressed || !bytes.HasPrefix(v, compressedMagic) {
+		return v, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(v[len(compressedMagic):]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// value returns the original value of stored value `v`, as a copy that's
+// safe to use after the transaction.
+func (bk *Bucket) value(v []byte) ([]byte, error) {
+	v, err := bk.decode(v)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, len(v))
+	copy(value, v)
+	return value, nil
+}
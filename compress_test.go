@@ -0,0 +1,154 @@
+package buckets_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure values in compressed buckets are stored compressed and read
+// back transparently.
+func TestCompressed(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	// Store a legacy value before compression is enabled.
+	plain, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	legacy := []byte(`{"task": "legacy"}`)
+	if err := plain.Put([]byte("/mon/08:00"), legacy); err != nil {
+		t.Error(err.Error())
+	}
+
+	todos, err := bx.NewCompressed([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	big := bytes.Repeat([]byte(`{"task": "compress me"}`), 100)
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("/mon/09:00"), big},
+		{[]byte("/mon/12:00"), []byte(`{"task": "lunch"}`)},
+		{[]byte("/tue/09:00"), []byte{}},
+	}
+	if err := todos.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	// The stored value should be smaller than the original.
+	var stored []byte
+	err = todos.View(func(b *bolt.Bucket) error {
+		stored = append(stored, b.Get([]byte("/mon/09:00"))...)
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(stored) >= len(big) {
+		t.Errorf("got stored size %d, want less than %d", len(stored), len(big))
+	}
+
+	got, err := todos.Get([]byte("/mon/09:00"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !bytes.Equal(got, big) {
+		t.Errorf("got %d bytes, want %d", len(got), len(big))
+	}
+
+	got, err = todos.Get([]byte("/mon/08:00"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !bytes.Equal(got, legacy) {
+		t.Errorf("got %q, want %q", got, legacy)
+	}
+
+	got, err = todos.Get([]byte("/tue/09:00"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got == nil || len(got) != 0 {
+		t.Errorf("got %q, want empty value", got)
+	}
+
+	// Scans decompress values too.
+	monday, err := todos.NewPrefixScanner([]byte("/mon/")).Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	expected := [][]byte{legacy, big, []byte(`{"task": "lunch"}`)}
+	if len(monday) != len(expected) {
+		t.Fatalf("got %d items, want %d", len(monday), len(expected))
+	}
+	for i, want := range expected {
+		if !bytes.Equal(monday[i].Value, want) {
+			t.Errorf("key %q: got %q, want %q", monday[i].Key, monday[i].Value, want)
+		}
+	}
+}
+
+// Ensure every bucket opened for a compressed bucket decompresses its
+// values, including after the db is reopened.
+func TestCompressedSettingsPersist(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+	bx, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	todos, err := bx.NewCompressed([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []byte(`{"task": "compress me"}`)
+	if err := todos.Put([]byte("a"), want); err != nil {
+		t.Error(err.Error())
+	}
+
+	plain, err := bx.Bucket([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := plain.Get([]byte("a")); !bytes.Equal(v, want) {
+		t.Errorf("got %q, want %q", v, want)
+	}
+	err = bx.View(func(tx *buckets.ReadTx) error {
+		bk, err := tx.Bucket([]byte("todos"))
+		if err != nil {
+			return err
+		}
+		v, err := bk.Get([]byte("a"))
+		if !bytes.Equal(v, want) {
+			t.Errorf("got %q in ReadTx, want %q", v, want)
+		}
+		return err
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if err := bx.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	bx, err = buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+	reopened, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := reopened.Get([]byte("a")); !bytes.Equal(v, want) {
+		t.Errorf("got %q after reopening, want %q", v, want)
+	}
+}
@@ -0,0 +1,134 @@
+package buckets
+
+import (
+	"context"
+
+	"github.com/boltdb/bolt"
+)
+
+// GetContext retrieves the value for key `k`, unless `ctx` is done.
+func (bk *Bucket) GetContext(ctx context.Context, k []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return bk.Get(k)
+}
+
+// PutContext inserts value `v` with key `k`, unless `ctx` is done.
+func (bk *Bucket) PutContext(ctx context.Context, k, v []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return bk.Put(k, v)
+}
+
+// ItemsContext returns a slice of key/value pairs, like Items.  The scan
+// stops early, returning the context's error, once `ctx` is done.
+func (bk *Bucket) ItemsContext(ctx context.Context) (items []Item, err error) {
+	defer bk.observe("ItemsContext", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if v != nil {
+				key := make([]byte, len(k))
+				copy(key, k)
+				value, err := bk.value(v)
+				if err != nil {
+					return err
+				}
+				items = append(items, Item{key, value})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ItemsContext returns a slice of key/value pairs for keys with prefix,
+// like Items.  The scan stops early, returning the context's error, once
+// `ctx` is done.
+func (ps *PrefixScanner) ItemsContext(ctx context.Context) (items []Item, err error) {
+	defer ps.bk.observe("PrefixScanner.ItemsContext", ps.Prefix)(&err)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.scan(tx, copied(func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			items = append(items, Item{k, v})
+			return nil
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ItemsContext returns a slice of key/value pairs for keys within the
+// range, like Items.  The scan stops early, returning the context's error,
+// once `ctx` is done.
+func (rs *RangeScanner) ItemsContext(ctx context.Context) (items []Item, err error) {
+	defer rs.bk.observe("RangeScanner.ItemsContext", nil)(&err)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.scan(tx, copied(func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			items = append(items, Item{k, v})
+			return nil
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Stream sends each key/value pair for keys with prefix on the returned
+// channel, which is closed once the scan is done or `ctx` is done.  The
+// pairs are copies, so they're safe to retain.  Once the channel is
+// closed, Err reports any error that stopped the scan.
+//
+// The scan runs in a goroutine within a read-only transaction that stays
+// open until the channel is closed, so be sure to receive every pair or
+// cancel `ctx`.
+func (ps *PrefixScanner) Stream(ctx context.Context) <-chan Item {
+	out := make(chan Item)
+	ps.err = nil
+	go func() {
+		defer close(out)
+		var err error
+		defer ps.bk.observe("PrefixScanner.Stream", ps.Prefix)(&err)
+		err = ps.bk.view(func(tx *bolt.Tx) error {
+			return ps.scan(tx, func(k, v []byte) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				item := Item{make([]byte, len(k)), make([]byte, len(v))}
+				copy(item.Key, k)
+				copy(item.Value, v)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case out <- item:
+					return nil
+				}
+			})
+		})
+		ps.err = err
+	}()
+	return out
+}
+
+// Err returns the error, if any, that stopped the last scan started by
+// Stream.  It should only be called once the channel returned by Stream
+// is closed.
+func (ps *PrefixScanner) Err() error {
+	return ps.err
+}
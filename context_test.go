@@ -0,0 +1,107 @@
+package buckets_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// Ensure context-aware methods work until the context is done.
+func TestContext(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	k, v := []byte("foo/"), []byte("foo")
+	if err := paths.PutContext(ctx, k, v); err != nil {
+		t.Error(err.Error())
+	}
+
+	got, err := paths.GetContext(ctx, k)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !bytes.Equal(got, v) {
+		t.Errorf("got %q, want %q", got, v)
+	}
+
+	items, err := paths.NewPrefixScanner([]byte("foo/")).ItemsContext(ctx)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 1 {
+		t.Errorf("got %d items, want 1", len(items))
+	}
+
+	cancel()
+
+	if err := paths.PutContext(ctx, []byte("foo/bar/"), v); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if _, err := paths.GetContext(ctx, k); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if _, err := paths.ItemsContext(ctx); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	scanner := paths.NewPrefixScanner([]byte("foo/"))
+	if _, err := scanner.ItemsContext(ctx); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	rs := paths.NewRangeScanner(nil, nil)
+	if _, err := rs.ItemsContext(ctx); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+// Ensure we can stream items with prefix, stopping when canceled.
+func TestPrefixScannerStream(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("foo/a"), []byte("a")},
+		{[]byte("foo/b"), []byte("b")},
+		{[]byte("foo/c"), []byte("c")},
+		{[]byte("goo/"), []byte("")},
+	}
+	if err := paths.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	foo := paths.NewPrefixScanner([]byte("foo/"))
+	var got []string
+	for item := range foo.Stream(context.Background()) {
+		got = append(got, string(item.Key)+"="+string(item.Value))
+	}
+	if err := foo.Err(); err != nil {
+		t.Error(err.Error())
+	}
+	if fmt.Sprint(got) != "[foo/a=a foo/b=b foo/c=c]" {
+		t.Errorf("got %q", got)
+	}
+
+	// Canceling the context stops the stream and is reported by Err.
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := foo.Stream(ctx)
+	<-stream
+	cancel()
+	for range stream {
+	}
+	if err := foo.Err(); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
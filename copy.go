@@ -0,0 +1,143 @@
+package buckets
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// CopyBucket copies each key/value pair in bucket `src` to bucket `dst`
+// as part of a single transaction, creating `dst` if it doesn't exist.
+// Existing keys in `dst` are overwritten.  Values are copied as stored,
+// and nested buckets aren't copied, but the persistent settings of `src`
+// (e.g., whether its keys can expire) are, so the copied values read
+// back the same.  If `dst` already holds keys stored with other
+// settings, CopyBucket returns an error.
+func (db *DB) CopyBucket(src, dst []byte) error {
+	_, err := db.copyBucket(src, dst, nil, 0)
+	return err
+}
+
+// CopyBucketN copies bucket `src` to bucket `dst` like CopyBucket, but
+// splits the copy into transactions of at most `size` items each, so that
+// copying a large bucket doesn't hold the write lock throughout.  Unlike
+// CopyBucket, the copy is not atomic: if a transaction fails, the items
+// copied by earlier transactions remain in `dst`, and changes made to
+// `src` between transactions may or may not be copied.  A `size` of zero
+// or less copies all of the items in a single transaction.
+func (db *DB) CopyBucketN(src, dst []byte, size int) error {
+	var from []byte
+	for {
+		next, err := db.copyBucket(src, dst, from, size)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		from = next
+	}
+}
+
+// RenameBucket renames bucket `old` to `new` as part of a single
+// transaction, by copying its keys and nested buckets to a new bucket
+// and removing the old one.  If bucket `new` already exists, RenameBucket
+// returns ErrBucketExists rather than merging the two.  Like Delete,
+// RenameBucket doesn't notify the database's watchers.
+func (db *DB) RenameBucket(old, new []byte) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		src := tx.Bucket(old)
+		if src == nil {
+			return ErrBucketNotFound
+		}
+		dst, err := tx.CreateBucket(new)
+		if err != nil {
+			return err
+		}
+		if err := copyAll(dst, src); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(old)
+	})
+}
+
+// copyAll copies each key/value pair and nested bucket in bolt bucket
+// `src` to bolt bucket `dst`.
+func copyAll(dst, src *bolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		child, err := dst.CreateBucketIfNotExists(k)
+		if err != nil {
+			return err
+		}
+		return copyAll(child, src.Bucket(k))
+	})
+}
+
+// copyBucket copies at most `n` items from bucket `src` to bucket `dst`,
+// starting with key `from`, in a single transaction.  An `n` of zero or
+// less copies all of the items.  It returns the key to start the next
+// copy from, or nil if there are no more items to copy.
+func (db *DB) copyBucket(src, dst, from []byte, n int) (next []byte, err error) {
+	bk := &Bucket{db: db, Name: dst}
+	r := &recorder{
+		bk:      bk,
+		record:  db.watcher.watching(),
+		indexes: db.indexesFor(bk),
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		s := tx.Bucket(src)
+		if s == nil {
+			return ErrBucketNotFound
+		}
+		d, err := tx.CreateBucketIfNotExists(dst)
+		if err != nil {
+			return err
+		}
+		if err := copyBucketSettings(tx, s, d, dst); err != nil {
+			return err
+		}
+		r.Bucket = d
+		copied := 0
+		c := s.Cursor()
+		for k, v := c.Seek(from); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			if n > 0 && copied == n {
+				next = make([]byte, len(k))
+				copy(next, k)
+				return nil
+			}
+			if err := r.Put(k, v); err != nil {
+				return err
+			}
+			copied++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.watcher.notify([][]byte{dst}, r.changes)
+	return next, nil
+}
+
+// copyBucketSettings copies the persistent settings of bolt bucket `src`
+// to bolt bucket `dst`, named `name`, unless they're the same.  If `dst`
+// already holds keys stored with other settings, it returns an error.
+func copyBucketSettings(tx *bolt.Tx, src, dst *bolt.Bucket, name []byte) error {
+	var from, to Bucket
+	from.loadSettings(src)
+	to.loadSettings(dst)
+	if from.sameSettings(&to) {
+		return nil
+	}
+	to.Name = name
+	if to.count(tx) > 0 {
+		return fmt.Errorf("bucket %s already has keys stored with other settings", name)
+	}
+	return copySettings(dst, src)
+}
@@ -0,0 +1,44 @@
+package buckets
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// NextSequence returns an autoincrementing integer for the bucket,
+// as part of a single transaction.
+func (bk *Bucket) NextSequence() (seq uint64, err error) {
+	defer bk.observe("NextSequence", nil)(&err)
+	err = bk.update(func(b *recorder) error {
+		seq, err = b.NextSequence()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// Increment adds `delta` to the counter stored with key `k`, returning
+// the new total.  The counter is stored as a big-endian int64, and a
+// missing key is treated as a counter at zero.  Reading, adding, and
+// storing the counter happen as part of a single transaction.
+func (bk *Bucket) Increment(k []byte, delta int64) (total int64, err error) {
+	defer bk.observe("Increment", k)(&err)
+	err = bk.update(func(b *recorder) error {
+		if v := b.Get(k); v != nil {
+			if len(v) != 8 {
+				return fmt.Errorf("value of %q isn't a counter: %q", k, v)
+			}
+			total = int64(binary.BigEndian.Uint64(v))
+		}
+		total += delta
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(total))
+		return b.Put(k, v)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,68 @@
+package buckets_test
+
+import (
+	"sync"
+	"testing"
+)
+
+// Ensure we get increasing sequence numbers for a bucket.
+func TestNextSequence(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	for want := uint64(1); want <= 3; want++ {
+		got, err := things.NextSequence()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != want {
+			t.Errorf("got sequence %d, want %d", got, want)
+		}
+	}
+}
+
+// Ensure concurrent increments of a counter aren't lost.
+func TestIncrement(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	counters, err := bx.New([]byte("counters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	k := []byte("hits")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := counters.Increment(k, 2); err != nil {
+				t.Error(err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	total, err := counters.Increment(k, -5)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if total != 15 {
+		t.Errorf("got total %d, want %d", total, 15)
+	}
+
+	// Values that aren't counters can't be incremented.
+	if err := counters.Put([]byte("name"), []byte("hits")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := counters.Increment([]byte("name"), 1); err == nil {
+		t.Error("expected error incrementing a non-counter value")
+	}
+}
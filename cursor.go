@@ -0,0 +1,65 @@
+package buckets
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// A cursor wraps a bolt cursor over a bucket's keys, skipping keys that
+// have expired (see NewTTL) and the bucket's settings bucket.  Keys of
+// other nested buckets aren't skipped.
+type cursor struct {
+	*bolt.Cursor
+	bk  *Bucket
+	now int64
+}
+
+// cursor returns a cursor over the bucket within transaction `tx`.
+func (bk *Bucket) cursor(tx *bolt.Tx) *cursor {
+	c := &cursor{Cursor: bk.bucket(tx).Cursor(), bk: bk}
+	if bk.ttl {
+		c.now = time.Now().UnixNano()
+	}
+	return c
+}
+
+// First moves the cursor to the first unexpired key.
+func (c *cursor) First() (key, value []byte) {
+	k, v := c.Cursor.First()
+	return c.skip(k, v, c.Cursor.Next)
+}
+
+// Last moves the cursor to the last unexpired key.
+func (c *cursor) Last() (key, value []byte) {
+	k, v := c.Cursor.Last()
+	return c.skip(k, v, c.Cursor.Prev)
+}
+
+// Next moves the cursor to the next unexpired key.
+func (c *cursor) Next() (key, value []byte) {
+	k, v := c.Cursor.Next()
+	return c.skip(k, v, c.Cursor.Next)
+}
+
+// Prev moves the cursor to the previous unexpired key.
+func (c *cursor) Prev() (key, value []byte) {
+	k, v := c.Cursor.Prev()
+	return c.skip(k, v, c.Cursor.Prev)
+}
+
+// Seek moves the cursor to the first unexpired key at or after `seek`.
+func (c *cursor) Seek(seek []byte) (key, value []byte) {
+	k, v := c.Cursor.Seek(seek)
+	return c.skip(k, v, c.Cursor.Next)
+}
+
+// skip moves the cursor with `next` while it's at an expired key or the
+// settings bucket.
+func (c *cursor) skip(k, v []byte, next func() ([]byte, []byte)) ([]byte, []byte) {
+	for k != nil && (c.bk.expired(v, c.now) || v == nil && bytes.Equal(k, settingsBucket)) {
+		k, v = next()
+	}
+	return k, v
+}
@@ -1,9 +1,13 @@
 package buckets_test
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/boltdb/bolt"
 	"github.com/joyrexus/buckets"
 )
 
@@ -16,3 +20,334 @@ func TestOpen(t *testing.T) {
 	defer os.Remove(bx.Path())
 	defer bx.Close()
 }
+
+// Ensure opening a locked db with a timeout fails rather than blocking.
+func TestOpenWith(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+
+	bx, err := buckets.OpenWith(path, 0640, &bolt.Options{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Errorf("got mode %v, want %v", mode, os.FileMode(0640))
+	}
+
+	// The file is locked by bx, so a second open times out.
+	start := time.Now()
+	_, err = buckets.OpenWith(path, 0640, &bolt.Options{Timeout: 50 * time.Millisecond})
+	if err == nil {
+		t.Fatal("expected timeout opening a locked db")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("open took %v, want it to fail fast", elapsed)
+	}
+}
+
+// Ensure Open applies its options in order.
+func TestOpenOptions(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+
+	bx, err := buckets.Open(path,
+		buckets.WithBoltOptions(&bolt.Options{NoGrowSync: true}),
+		buckets.WithTimeout(50*time.Millisecond),
+		buckets.WithFileMode(0640),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bx.NoGrowSync {
+		t.Error("bolt options not applied")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Errorf("got mode %v, want %v", mode, os.FileMode(0640))
+	}
+
+	start := time.Now()
+	if _, err := buckets.Open(path, buckets.WithTimeout(50*time.Millisecond)); err == nil {
+		t.Error("expected timeout opening a locked db")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("open took %v, want it to fail fast", elapsed)
+	}
+	bx.Close()
+
+	ro, err := buckets.Open(path, buckets.ReadOnly())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro.Close()
+	if _, err := ro.New([]byte("things")); err != buckets.ErrReadOnly {
+		t.Errorf("got %v, want ErrReadOnly", err)
+	}
+}
+
+// Ensure a db opened read-only can be read but not written.
+func TestOpenReadOnly(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+
+	bx, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	bx.Close()
+
+	// Several readers can open the file at once.
+	ro, err := buckets.OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro.Close()
+	ro2, err := buckets.OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer ro2.Close()
+
+	things, err = ro.Bucket([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := things.Get([]byte("A")); string(v) != "alpha" {
+		t.Errorf("got %q, want %q", v, "alpha")
+	}
+
+	if err := things.Put([]byte("B"), []byte("beta")); err != buckets.ErrReadOnly {
+		t.Errorf("Put: got %v, want ErrReadOnly", err)
+	}
+	if err := things.Delete([]byte("A")); err != buckets.ErrReadOnly {
+		t.Errorf("Delete: got %v, want ErrReadOnly", err)
+	}
+	if _, err := ro.New([]byte("letters")); err != buckets.ErrReadOnly {
+		t.Errorf("New: got %v, want ErrReadOnly", err)
+	}
+}
+
+// Ensure we can list and drop the buckets in a db.
+func TestList(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	for _, name := range []string{"things", "letters", "paths"} {
+		if _, err := bx.New([]byte(name)); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	if err := bx.Drop([]byte("paths")); err != nil {
+		t.Error(err.Error())
+	}
+
+	names, err := bx.List()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := []string{"letters", "things"}
+	if len(names) != len(expected) {
+		t.Fatalf("got %d buckets, want %d", len(names), len(expected))
+	}
+	for i, want := range expected {
+		if got := names[i]; string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+
+	// Buckets is an alias for List.
+	aliased, err := bx.Buckets()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(aliased) != len(names) {
+		t.Errorf("got %q, want %q", aliased, names)
+	}
+}
+
+// Ensure we can open an existing bucket without creating it.
+func TestBucketExisting(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	if _, err := bx.Bucket([]byte("things")); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+	names, err := bx.List()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(names) != 0 {
+		t.Errorf("got %q, want no buckets created", names)
+	}
+
+	if _, err := bx.New([]byte("things")); err != nil {
+		t.Error(err.Error())
+	}
+	things, err := bx.Bucket([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure we can copy a bucket, in one transaction or several.
+func TestCopyBucket(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("bravo")},
+		{[]byte("C"), []byte("charlie")},
+		{[]byte("D"), []byte("")},
+		{[]byte("E"), []byte("echo")},
+	}
+	if err := todos.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	check := func(dst string) {
+		bk, err := bx.Bucket([]byte(dst))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		got, err := bk.Items()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(got) != len(items) {
+			t.Errorf("%s: got %d items, want %d", dst, len(got), len(items))
+			return
+		}
+		for i, want := range items {
+			if !bytes.Equal(got[i].Key, want.Key) || !bytes.Equal(got[i].Value, want.Value) {
+				t.Errorf("%s: got %q, want %q", dst, got[i], want)
+			}
+		}
+	}
+
+	if err := bx.CopyBucket([]byte("todos"), []byte("todos_copy")); err != nil {
+		t.Fatal(err.Error())
+	}
+	check("todos_copy")
+
+	for _, size := range []int{0, 1, 2, 5, 10} {
+		dst := fmt.Sprintf("todos_%d", size)
+		if err := bx.CopyBucketN([]byte("todos"), []byte(dst), size); err != nil {
+			t.Fatal(err.Error())
+		}
+		check(dst)
+	}
+
+	if err := bx.CopyBucket([]byte("missing"), []byte("dst")); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+}
+
+// Ensure we can rename a bucket, but not onto an existing one.
+func TestRenameBucket(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	v1, err := bx.New([]byte("todos_v1"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := v1.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	child, err := v1.NewChild([]byte("done"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := child.Put([]byte("B"), []byte("bravo")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := bx.New([]byte("todos_v3")); err != nil {
+		t.Error(err.Error())
+	}
+
+	if err := bx.RenameBucket([]byte("todos_v1"), []byte("todos_v3")); err != buckets.ErrBucketExists {
+		t.Errorf("got %v, want ErrBucketExists", err)
+	}
+	if err := bx.RenameBucket([]byte("missing"), []byte("todos_v4")); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+
+	if err := bx.RenameBucket([]byte("todos_v1"), []byte("todos_v2")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := bx.Bucket([]byte("todos_v1")); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want old bucket removed", err)
+	}
+	v2, err := bx.Bucket([]byte("todos_v2"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := v2.Get([]byte("A")); string(v) != "alpha" {
+		t.Errorf("got %q, want %q", v, "alpha")
+	}
+	done, err := v2.NewChild([]byte("done"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := done.Get([]byte("B")); string(v) != "bravo" {
+		t.Errorf("got %q, want nested bucket copied", v)
+	}
+}
+
+func ExampleDB_RenameBucket() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	// Create a `todos_v1` bucket and a `todos_v2` bucket.
+	v1, _ := bx.New([]byte("todos_v1"))
+	v1.Put([]byte("A"), []byte("alpha"))
+	bx.New([]byte("todos_v2"))
+
+	// Renaming onto an existing bucket fails, rather than merging them.
+	err := bx.RenameBucket([]byte("todos_v1"), []byte("todos_v2"))
+	fmt.Println(err)
+
+	// Renaming onto a new bucket moves all of the keys.
+	bx.RenameBucket([]byte("todos_v1"), []byte("todos"))
+	todos, _ := bx.Bucket([]byte("todos"))
+	v, _ := todos.Get([]byte("A"))
+	fmt.Printf("%s\n", v)
+
+	names, _ := bx.List()
+	fmt.Printf("%s\n", names)
+
+	// Output:
+	// bucket already exists
+	// alpha
+	// [todos todos_v2]
+}
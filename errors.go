@@ -0,0 +1,39 @@
+package buckets
+
+import (
+	"errors"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	// ErrStop can be returned by a func passed to a ForEach method to
+	// stop iterating without causing ForEach to return an error.
+	ErrStop = errors.New("stop iteration")
+
+	// ErrKeyNotFound is returned when a key that must exist doesn't.
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrEmpty is returned when there's no item to return, e.g., when
+	// getting the first item of an empty scan.
+	ErrEmpty = errors.New("no items")
+
+	// ErrBucketExists is returned when a bucket that mustn't exist does.
+	// It's the same error bolt returns when creating existing buckets.
+	ErrBucketExists = bolt.ErrBucketExists
+
+	// ErrOutOfRange is returned when a position is past the last key.
+	ErrOutOfRange = errors.New("position out of range")
+
+	// ErrBucketNotFound is returned when a bucket that must exist doesn't.
+	// It's the same error bolt returns for missing buckets.
+	ErrBucketNotFound = bolt.ErrBucketNotFound
+
+	// ErrBucketFull is returned when putting a new key in a bucket that
+	// already holds as many keys as it may (see WithMaxKeys).
+	ErrBucketFull = errors.New("bucket full")
+
+	// ErrReadOnly is returned when writing to a database opened with
+	// OpenReadOnly.  It's the same error bolt returns for such writes.
+	ErrReadOnly = bolt.ErrDatabaseReadOnly
+)
@@ -0,0 +1,241 @@
+package buckets
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/boltdb/bolt"
+)
+
+// A jsonItem is the JSON form of a k/v pair, as written by ExportJSON.
+// Keys and values are base64 encoded, so binary data round trips.
+type jsonItem struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// ExportJSON writes each key/value pair in the bucket to `w` as a JSON
+// object per line (`{"key":"<base64>","value":"<base64>"}`).  The pairs
+// are written as they're read, within a single read-only transaction,
+// so the export is a consistent snapshot and isn't buffered in memory.
+func (bk *Bucket) ExportJSON(w io.Writer) (err error) {
+	defer bk.observe("ExportJSON", nil)(&err)
+	enc := json.NewEncoder(w)
+	return bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(jsonItem{k, v}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ImportJSON reads key/value pairs written by ExportJSON from `r`,
+// putting them in the bucket as part of a single transaction.  It
+// returns the number of pairs imported.  If the input can't be decoded,
+// none of the pairs are imported.
+func (bk *Bucket) ImportJSON(r io.Reader) (int, error) {
+	var items []Item
+	dec := json.NewDecoder(r)
+	for {
+		var item jsonItem
+		if err := dec.Decode(&item); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		items = append(items, Item{item.Key, item.Value})
+	}
+	if err := bk.PutBatch(items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// DumpTo writes each key/value pair in the bucket to `w` in a simple
+// binary format: the key's length as a 4-byte little-endian integer, the
+// key, the value's length in the same way, then the value.  Like
+// ExportJSON, the pairs are written as they're read, within a single
+// read-only transaction.  DumpTo returns the number of bytes written.
+func (bk *Bucket) DumpTo(w io.Writer) (n int64, err error) {
+	defer bk.observe("DumpTo", nil)(&err)
+	var size [4]byte
+	write := func(b []byte) error {
+		binary.LittleEndian.PutUint32(size[:], uint32(len(b)))
+		for _, p := range [][]byte{size[:], b} {
+			m, err := w.Write(p)
+			n += int64(m)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
+			if err := write(k); err != nil {
+				return err
+			}
+			if err := write(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// LoadFrom reads key/value pairs written by DumpTo from `r`, putting them
+// in the bucket as part of a single transaction.  If the input is
+// truncated or can't be read, none of the pairs are loaded.
+func (bk *Bucket) LoadFrom(r io.Reader) error {
+	var size [4]byte
+	read := func() ([]byte, error) {
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, err
+		}
+		b := make([]byte, binary.LittleEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return b, nil
+	}
+	var items []Item
+	for {
+		k, err := read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		v, err := read()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		items = append(items, Item{k, v})
+	}
+	return bk.PutBatch(items)
+}
+
+// Export writes each key/value pair in the named bucket to `w`, in
+// `format`: "json" for the newline-delimited JSON written by ExportJSON,
+// or "csv" for two-column CSV records of hex-encoded key and
+// base64-encoded value.  If the bucket doesn't exist, Export returns
+// ErrBucketNotFound.
+func (db *DB) Export(w io.Writer, name []byte, format string) error {
+	bk, err := db.Bucket(name)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		return bk.ExportJSON(w)
+	case "csv":
+		return bk.exportCSV(w)
+	}
+	return fmt.Errorf("unknown export format: %q", format)
+}
+
+// Import reads key/value pairs written by Export in `format` from `r`,
+// putting them in the named bucket, which is created if it doesn't
+// exist, as part of a single transaction.  If the input can't be
+// decoded, none of the pairs are imported.
+func (db *DB) Import(r io.Reader, name []byte, format string) error {
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("unknown import format: %q", format)
+	}
+	bk, err := db.New(name)
+	if err != nil {
+		return err
+	}
+	if format == "csv" {
+		return bk.importCSV(r)
+	}
+	_, err = bk.ImportJSON(r)
+	return err
+}
+
+// exportCSV writes each key/value pair in the bucket to `w` as a CSV
+// record of hex-encoded key and base64-encoded value.
+func (bk *Bucket) exportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := bk.decode(v)
+			if err != nil {
+				return err
+			}
+			record := []string{
+				hex.EncodeToString(k),
+				base64.StdEncoding.EncodeToString(v),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// importCSV reads key/value pairs written by exportCSV from `r`, putting
+// them in the bucket as part of a single transaction.
+func (bk *Bucket) importCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	var items []Item
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		k, err := hex.DecodeString(record[0])
+		if err != nil {
+			return err
+		}
+		v, err := base64.StdEncoding.DecodeString(record[1])
+		if err != nil {
+			return err
+		}
+		items = append(items, Item{k, v})
+	}
+	return bk.PutBatch(items)
+}
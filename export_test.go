@@ -0,0 +1,201 @@
+package buckets_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can export a bucket as JSON and import it into another.
+func TestExportImportJSON(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	src, err := bx.New([]byte("src"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	dst, err := bx.New([]byte("dst"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte{0x00, 0xff, 0x10}},
+		{[]byte{0xfe, 0x01}, []byte("")},
+	}
+	if err := src.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := src.ExportJSON(&buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(items) {
+		t.Errorf("got %d lines, want %d", lines, len(items))
+	}
+
+	n, err := dst.ImportJSON(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != len(items) {
+		t.Errorf("got %d imported, want %d", n, len(items))
+	}
+
+	for _, want := range items {
+		got, err := dst.Get(want.Key)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got == nil || !bytes.Equal(got, want.Value) {
+			t.Errorf("key %q: got %q, want %q", want.Key, got, want.Value)
+		}
+	}
+
+	// Malformed input imports nothing.
+	if _, err := dst.ImportJSON(strings.NewReader(`{"key":"Qw==","value":""}` + "\n{")); err == nil {
+		t.Error("expected error for malformed input")
+	}
+	if ok, _ := dst.Has([]byte("C")); ok {
+		t.Error("malformed import should not put any items")
+	}
+}
+
+// Ensure we can dump a bucket in binary form and load it into another.
+func TestDumpLoad(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	src, err := bx.New([]byte("src"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	dst, err := bx.New([]byte("dst"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte{0x00, 0xff, 0x10}},
+		{[]byte("C"), []byte("")},
+	}
+	if err := src.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	var buf bytes.Buffer
+	n, err := src.DumpTo(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// Each item takes 8 bytes of lengths plus its key and value.
+	want := int64(8*3 + 1 + 5 + 1 + 3 + 1)
+	if n != want || int64(buf.Len()) != want {
+		t.Errorf("got %d bytes (%d buffered), want %d", n, buf.Len(), want)
+	}
+
+	dump := buf.Bytes()
+	if err := dst.LoadFrom(bytes.NewReader(dump)); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, want := range items {
+		got, err := dst.Get(want.Key)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got == nil || !bytes.Equal(got, want.Value) {
+			t.Errorf("key %q: got %q, want %q", want.Key, got, want.Value)
+		}
+	}
+
+	// Truncated input loads nothing.
+	other, err := bx.New([]byte("other"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := other.LoadFrom(bytes.NewReader(dump[:len(dump)-1])); err == nil {
+		t.Error("expected error for truncated input")
+	}
+	if count, _ := other.Count(); count != 0 {
+		t.Errorf("got %d items after truncated load, want 0", count)
+	}
+}
+
+// Ensure we can export a named bucket as JSON or CSV and import it back.
+func TestDBExportImport(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	src, err := bx.New([]byte("src"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha, bravo")},
+		{[]byte("B"), []byte{0x00, 0xff, 0x10}},
+		{[]byte{0xfe, 0x01}, []byte("")},
+	}
+	if err := src.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	for _, format := range []string{"json", "csv"} {
+		var buf bytes.Buffer
+		if err := bx.Export(&buf, []byte("src"), format); err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if lines := strings.Count(buf.String(), "\n"); lines != len(items) {
+			t.Errorf("%s: got %d lines, want %d", format, lines, len(items))
+		}
+		name := []byte("dst-" + format)
+		if err := bx.Import(&buf, name, format); err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		dst, err := bx.Bucket(name)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		for _, want := range items {
+			got, err := dst.Get(want.Key)
+			if err != nil {
+				t.Error(err.Error())
+			}
+			if got == nil || !bytes.Equal(got, want.Value) {
+				t.Errorf("%s: key %q: got %q, want %q", format, want.Key, got, want.Value)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := bx.Export(&buf, []byte("src"), "xml"); err == nil {
+		t.Error("expected error for unknown export format")
+	}
+	if err := bx.Export(&buf, []byte("missing"), "json"); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+	if err := bx.Import(&buf, []byte("dst"), "xml"); err == nil {
+		t.Error("expected error for unknown import format")
+	}
+
+	// Malformed CSV imports nothing.
+	if err := bx.Import(strings.NewReader("41,YQ==\n42\n"), []byte("bad"), "csv"); err == nil {
+		t.Error("expected error for malformed input")
+	}
+	if bad, err := bx.Bucket([]byte("bad")); err == nil {
+		if ok, _ := bad.Has([]byte("A")); ok {
+			t.Error("malformed import should not put any items")
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package buckets
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// A FieldIndex is an in-memory inverted index mapping the values of a
+// JSON field to the keys of the items in a bucket with that value.
+//
+// The index is a snapshot of the bucket's contents when it was built.
+// Use Rebuild to refresh it after the bucket changes.
+type FieldIndex struct {
+	bk    *Bucket
+	Field string
+	mu    sync.RWMutex
+	keys  map[string][][]byte
+}
+
+// FieldIndex builds an index of the values of `field` across the items
+// in the bucket, whose values should be JSON objects.  Nested fields
+// are specified by a dot-separated path (e.g., "author.name").  Items
+// whose value isn't a JSON object or doesn't have a string, number, or
+// boolean value for the field aren't indexed.
+func (bk *Bucket) FieldIndex(field string) (*FieldIndex, error) {
+	fi := &FieldIndex{bk: bk, Field: field}
+	if err := fi.Rebuild(); err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// Rebuild refreshes the index from the current contents of the bucket.
+func (fi *FieldIndex) Rebuild() error {
+	path := strings.Split(fi.Field, ".")
+	keys := make(map[string][][]byte)
+	err := fi.bk.view(func(tx *bolt.Tx) error {
+		c := fi.bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := fi.bk.decode(v)
+			if err != nil {
+				return err
+			}
+			value, ok := fieldValue(v, path)
+			if !ok {
+				continue
+			}
+			key := make([]byte, len(k))
+			copy(key, k)
+			keys[value] = append(keys[value], key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fi.mu.Lock()
+	fi.keys = keys
+	fi.mu.Unlock()
+	return nil
+}
+
+// Keys returns the keys of the indexed items whose field has `value`.
+func (fi *FieldIndex) Keys(value string) [][]byte {
+	fi.mu.RLock()
+	defer fi.mu.RUnlock()
+	return fi.keys[value]
+}
+
+// Lookup returns the items whose field has `value`.  Items deleted
+// since the index was built are skipped.
+func (fi *FieldIndex) Lookup(value string) ([]Item, error) {
+	found, err := fi.bk.GetBatch(fi.Keys(value))
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, item := range found {
+		if item.Value != nil {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// fieldValue decodes the JSON object `v` and returns the value of the
+// field at `path` as a string.
+func fieldValue(v []byte, path []string) (string, bool) {
+	dec := json.NewDecoder(bytes.NewReader(v))
+	dec.UseNumber()
+	var field interface{}
+	if err := dec.Decode(&field); err != nil {
+		return "", false
+	}
+	for _, name := range path {
+		obj, ok := field.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if field, ok = obj[name]; !ok {
+			return "", false
+		}
+	}
+	switch value := field.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		if value {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
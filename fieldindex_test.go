@@ -0,0 +1,86 @@
+package buckets_test
+
+import (
+	"testing"
+)
+
+// Ensure we can index items by the value of a JSON field.
+func TestFieldIndex(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1"), []byte(`{"task": "milk", "day": "mon", "owner": {"name": "al"}}`)},
+		{[]byte("2"), []byte(`{"task": "eggs", "day": "tue", "owner": {"name": "bo"}}`)},
+		{[]byte("3"), []byte(`{"task": "bread", "day": "mon", "owner": {"name": "bo"}}`)},
+		{[]byte("4"), []byte(`{"task": "jam", "priority": 1}`)},
+		{[]byte("5"), []byte(`not json`)},
+	}
+
+	if err := todos.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	byDay, err := todos.FieldIndex("day")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	monday, err := byDay.Lookup("mon")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(monday) != 2 || string(monday[0].Key) != "1" || string(monday[1].Key) != "3" {
+		t.Errorf("got %q, want items with keys 1 and 3", monday)
+	}
+
+	// Nested and non-string fields can be indexed too.
+	byOwner, err := todos.FieldIndex("owner.name")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if keys := byOwner.Keys("bo"); len(keys) != 2 {
+		t.Errorf("got %d keys owned by bo, want 2", len(keys))
+	}
+	byPriority, err := todos.FieldIndex("priority")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if keys := byPriority.Keys("1"); len(keys) != 1 || string(keys[0]) != "4" {
+		t.Errorf("got %q, want key 4", keys)
+	}
+
+	// The index only reflects changes once rebuilt.
+	if err := todos.Put([]byte("6"), []byte(`{"task": "tea", "day": "mon"}`)); err != nil {
+		t.Error(err.Error())
+	}
+	if err := todos.Delete([]byte("1")); err != nil {
+		t.Error(err.Error())
+	}
+
+	monday, err = byDay.Lookup("mon")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(monday) != 1 || string(monday[0].Key) != "3" {
+		t.Errorf("got %q, want item with key 3", monday)
+	}
+
+	if err := byDay.Rebuild(); err != nil {
+		t.Error(err.Error())
+	}
+	monday, err = byDay.Lookup("mon")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(monday) != 2 || string(monday[0].Key) != "3" || string(monday[1].Key) != "6" {
+		t.Errorf("got %q, want items with keys 3 and 6", monday)
+	}
+}
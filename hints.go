@@ -0,0 +1,38 @@
+package buckets
+
+// A Hint describes how keys are written to a bucket, so that the bucket
+// can be tuned for the access pattern.
+type Hint int
+
+const (
+	// HintSequential indicates that keys are mostly appended in
+	// ascending order, e.g., timestamps or sequence numbers.  Pages are
+	// filled completely before being split, since keys won't later be
+	// inserted between them.
+	HintSequential Hint = iota + 1
+	// HintRandom indicates that keys are inserted in no particular
+	// order.  Pages are split when a quarter full, leaving room for keys
+	// inserted later to fit without further splits.
+	HintRandom
+)
+
+// fillPercent returns the bolt FillPercent suited to the hint.
+func (h Hint) fillPercent() float64 {
+	switch h {
+	case HintSequential:
+		return 1.0
+	case HintRandom:
+		return 0.25
+	}
+	return 0
+}
+
+// WithHint returns a copy of the bucket whose writes are tuned for the
+// access pattern described by `h`, by setting the FillPercent of the
+// underlying bolt bucket.  Reads aren't affected, since bolt's cursors
+// have no such tuning parameters.
+func (bk *Bucket) WithHint(h Hint) *Bucket {
+	bucket := *bk
+	bucket.fill = h.fillPercent()
+	return &bucket
+}
@@ -0,0 +1,45 @@
+package buckets_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure a sequential hint packs sequentially written keys more densely.
+func TestWithHint(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	plain, err := bx.New([]byte("plain"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	hinted, err := bx.New([]byte("hinted"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	hinted = hinted.WithHint(buckets.HintSequential)
+
+	for _, bk := range []*buckets.Bucket{plain, hinted} {
+		items := make([]buckets.Item, 2000)
+		for i := range items {
+			k := make([]byte, 8)
+			binary.BigEndian.PutUint64(k, uint64(i))
+			items[i] = buckets.Item{Key: k, Value: []byte("value")}
+		}
+		if err := bk.PutBatch(items); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	stats, err := bx.Stats()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	p, h := stats["plain"].LeafPageCount, stats["hinted"].LeafPageCount
+	if h >= p {
+		t.Errorf("got %d leaf pages with hint, want fewer than %d without", h, p)
+	}
+}
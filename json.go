@@ -0,0 +1,26 @@
+package buckets
+
+import "encoding/json"
+
+// PutJSON inserts the JSON encoding of `v` with key `k`.
+func (bk *Bucket) PutJSON(k []byte, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bk.Put(k, value)
+}
+
+// GetJSON retrieves the value for key `k` and decodes it as JSON into
+// `dst`.  If the key doesn't exist, GetJSON returns ErrKeyNotFound; if the
+// value isn't valid JSON for `dst`, it returns the decoding error.
+func (bk *Bucket) GetJSON(k []byte, dst interface{}) error {
+	value, err := bk.Get(k)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return ErrKeyNotFound
+	}
+	return json.Unmarshal(value, dst)
+}
@@ -0,0 +1,54 @@
+package buckets_test
+
+import (
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can put and get values as JSON.
+func TestPutGetJSON(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	type Todo struct {
+		Task string
+		Done bool
+	}
+
+	want := Todo{"milk cows", true}
+	if err := todos.PutJSON([]byte("1"), want); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := todos.Get([]byte("1")); string(v) != `{"Task":"milk cows","Done":true}` {
+		t.Errorf("got %s, want JSON encoding", v)
+	}
+
+	var got Todo
+	if err := todos.GetJSON([]byte("1"), &got); err != nil {
+		t.Error(err.Error())
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if err := todos.GetJSON([]byte("2"), &got); err != buckets.ErrKeyNotFound {
+		t.Errorf("got %v, want ErrKeyNotFound", err)
+	}
+
+	if err := todos.Put([]byte("3"), []byte("not json")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := todos.GetJSON([]byte("3"), &got); err == nil || err == buckets.ErrKeyNotFound {
+		t.Errorf("got %v, want JSON decoding error", err)
+	}
+
+	if err := todos.PutJSON([]byte("4"), func() {}); err == nil {
+		t.Error("expected error encoding a func")
+	}
+}
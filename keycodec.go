@@ -0,0 +1,147 @@
+package buckets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// A KeyCodec encodes and decodes the typed keys passed to PutKey, GetKey,
+// and DeleteKey, and returned by DecodeKey.  For scans to visit keys in
+// order, encoded keys must sort in the same order as the keys they encode.
+type KeyCodec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(b []byte) (interface{}, error)
+}
+
+var (
+	// StringKeyCodec encodes string keys as is.
+	StringKeyCodec KeyCodec = stringKeyCodec{}
+	// Uint64KeyCodec encodes uint64 keys as 8-byte big-endian integers,
+	// which sort in numeric order.
+	Uint64KeyCodec KeyCodec = uint64KeyCodec{}
+	// TimeKeyCodec encodes time.Time keys as RFC 3339 timestamps in UTC,
+	// with nanoseconds.  Unlike time.RFC3339Nano, trailing zeros aren't
+	// trimmed, so the timestamps are of fixed width and sort in time
+	// order.  Decoded keys are in UTC.
+	TimeKeyCodec KeyCodec = timeKeyCodec{}
+)
+
+// timeKeyLayout is the layout of keys encoded by TimeKeyCodec.
+const timeKeyLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+type stringKeyCodec struct{}
+
+func (stringKeyCodec) Encode(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("can't encode %T as a string key", v)
+	}
+	return []byte(s), nil
+}
+
+func (stringKeyCodec) Decode(b []byte) (interface{}, error) {
+	return string(b), nil
+}
+
+type uint64KeyCodec struct{}
+
+func (uint64KeyCodec) Encode(v interface{}) ([]byte, error) {
+	n, ok := v.(uint64)
+	if !ok {
+		return nil, fmt.Errorf("can't encode %T as a uint64 key", v)
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b, nil
+}
+
+func (uint64KeyCodec) Decode(b []byte) (interface{}, error) {
+	if len(b) != 8 {
+		return nil, fmt.Errorf("can't decode %d-byte key as a uint64", len(b))
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+type timeKeyCodec struct{}
+
+func (timeKeyCodec) Encode(v interface{}) ([]byte, error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("can't encode %T as a time key", v)
+	}
+	return []byte(t.UTC().Format(timeKeyLayout)), nil
+}
+
+func (timeKeyCodec) Decode(b []byte) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, string(b))
+}
+
+// bytesKeyCodec encodes []byte keys as is.  It's the default key codec.
+type bytesKeyCodec struct{}
+
+func (bytesKeyCodec) Encode(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("can't encode %T as a []byte key", v)
+	}
+	return b, nil
+}
+
+func (bytesKeyCodec) Decode(b []byte) (interface{}, error) {
+	return b, nil
+}
+
+// WithKeyCodec returns a copy of the bucket whose PutKey, GetKey,
+// DeleteKey, and DecodeKey methods use key codec `kc`.  Other methods
+// take and return keys as is.
+func (bk *Bucket) WithKeyCodec(kc KeyCodec) *Bucket {
+	bucket := *bk
+	bucket.keyCodec = kc
+	return &bucket
+}
+
+// PutKey inserts value `v` with the encoding of key `key`, using the
+// bucket's key codec.  Without a key codec, keys must be of type []byte.
+func (bk *Bucket) PutKey(key interface{}, v []byte) error {
+	k, err := bk.encodeKey(key)
+	if err != nil {
+		return err
+	}
+	return bk.Put(k, v)
+}
+
+// GetKey retrieves the value for the encoding of key `key`, like Get.
+func (bk *Bucket) GetKey(key interface{}) ([]byte, error) {
+	k, err := bk.encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return bk.Get(k)
+}
+
+// DeleteKey removes the encoding of key `key`, like Delete.
+func (bk *Bucket) DeleteKey(key interface{}) error {
+	k, err := bk.encodeKey(key)
+	if err != nil {
+		return err
+	}
+	return bk.Delete(k)
+}
+
+// DecodeKey decodes key `k`, e.g., as returned by a scan, using the
+// bucket's key codec.
+func (bk *Bucket) DecodeKey(k []byte) (interface{}, error) {
+	if bk.keyCodec == nil {
+		return bytesKeyCodec{}.Decode(k)
+	}
+	return bk.keyCodec.Decode(k)
+}
+
+// encodeKey encodes key `key` using the bucket's key codec.
+func (bk *Bucket) encodeKey(key interface{}) ([]byte, error) {
+	if bk.keyCodec == nil {
+		return bytesKeyCodec{}.Encode(key)
+	}
+	return bk.keyCodec.Encode(key)
+}
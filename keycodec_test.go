@@ -0,0 +1,108 @@
+package buckets_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can put, get, and delete items with typed keys.
+func TestKeyCodec(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	events, err := bx.New([]byte("events"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Without a key codec, keys must be byte slices.
+	if err := events.PutKey("a", []byte("x")); err == nil {
+		t.Error("got no error putting string key with default key codec")
+	}
+
+	byID := events.WithKeyCodec(buckets.Uint64KeyCodec)
+	for _, id := range []uint64{256, 1, 70000} {
+		if err := byID.PutKey(id, []byte("event")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	if err := byID.PutKey(2, []byte("event")); err == nil {
+		t.Error("got no error putting int key with Uint64KeyCodec")
+	}
+	if v, err := byID.GetKey(uint64(256)); err != nil || string(v) != "event" {
+		t.Errorf("got %q, %v; want %q", v, err, "event")
+	}
+
+	// Keys are visited in numeric order.
+	items, err := byID.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := []uint64{1, 256, 70000}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		id, err := byID.DecodeKey(item.Key)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if id != want[i] {
+			t.Errorf("got key %v, want %v", id, want[i])
+		}
+	}
+
+	if err := byID.DeleteKey(uint64(256)); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := byID.GetKey(uint64(256)); v != nil {
+		t.Errorf("got %q for deleted key, want nil", v)
+	}
+}
+
+// Ensure the built-in key codecs round-trip keys and preserve their order.
+func TestBuiltinKeyCodecs(t *testing.T) {
+	base := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		kc         buckets.KeyCodec
+		small, big interface{}
+	}{
+		{buckets.StringKeyCodec, "apple", "banana"},
+		{buckets.Uint64KeyCodec, uint64(9), uint64(10)},
+		{buckets.TimeKeyCodec, base, base.Add(500 * time.Millisecond)},
+		{buckets.TimeKeyCodec, base.Add(123 * time.Millisecond),
+			base.Add(500 * time.Millisecond)},
+	} {
+		small, err := tt.kc.Encode(tt.small)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		big, err := tt.kc.Encode(tt.big)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(small) >= string(big) {
+			t.Errorf("got %q >= %q, want encoded %v before %v",
+				small, big, tt.small, tt.big)
+		}
+		got, err := tt.kc.Decode(big)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != tt.big {
+			t.Errorf("got %v, want %v", got, tt.big)
+		}
+	}
+
+	// Times are encoded in UTC.
+	est := time.FixedZone("EST", -5*60*60)
+	k, _ := buckets.TimeKeyCodec.Encode(base.In(est))
+	if got, _ := buckets.TimeKeyCodec.Decode(k); !got.(time.Time).Equal(base) {
+		t.Errorf("got %v, want %v", got, base)
+	}
+	if _, err := buckets.Uint64KeyCodec.Decode([]byte("abc")); err == nil {
+		t.Error("got no error decoding 3-byte key as uint64")
+	}
+}
@@ -0,0 +1,32 @@
+package buckets
+
+// WithMaxKeys returns a copy of the bucket whose writes keep it to at
+// most `n` keys: once the bucket holds `n` keys, putting a new key
+// returns ErrBucketFull, though existing keys can still be updated.  A
+// write of several keys, e.g., with PutBatch, that would go over the
+// limit fails as a whole.  Writes through other copies of the bucket
+// aren't limited.  An `n` of zero or less removes the limit.
+//
+// Checking the limit counts the bucket's keys, once per transaction, so
+// it takes time proportional to the size of the bucket.
+func (bk *Bucket) WithMaxKeys(n int) *Bucket {
+	bucket := *bk
+	bucket.maxKeys = n
+	return &bucket
+}
+
+// reserve checks that key `k` can be put without going over the bucket's
+// limit on keys, counting it if it's a new key.
+func (r *recorder) reserve(k []byte) error {
+	if r.bk.maxKeys <= 0 || r.bk.get(r.Tx(), k) != nil {
+		return nil
+	}
+	if !r.counted {
+		r.keys, r.counted = r.bk.count(r.Tx()), true
+	}
+	if r.keys >= r.bk.maxKeys {
+		return ErrBucketFull
+	}
+	r.keys++
+	return nil
+}
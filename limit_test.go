@@ -0,0 +1,114 @@
+package buckets_test
+
+import (
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can't put new keys in a bucket holding its maximum number.
+func TestWithMaxKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	cache, err := bx.New([]byte("cache"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	limited := cache.WithMaxKeys(3)
+	for _, k := range []string{"a", "b", "c"} {
+		if err := limited.Put([]byte(k), []byte("1")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	if err := limited.Put([]byte("d"), []byte("1")); err != buckets.ErrBucketFull {
+		t.Errorf("got %v, want ErrBucketFull", err)
+	}
+
+	// Existing keys can still be updated.
+	if err := limited.Put([]byte("a"), []byte("2")); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := cache.Get([]byte("a")); string(v) != "2" {
+		t.Errorf("got %q, want %q", v, "2")
+	}
+
+	// A batch with a new key fails as a whole.
+	err = limited.PutBatch([]buckets.Item{
+		{Key: []byte("a"), Value: []byte("3")},
+		{Key: []byte("e"), Value: []byte("1")},
+	})
+	if err != buckets.ErrBucketFull {
+		t.Errorf("got %v, want ErrBucketFull", err)
+	}
+	if v, _ := cache.Get([]byte("a")); string(v) != "2" {
+		t.Errorf("got %q from failed batch, want %q", v, "2")
+	}
+
+	// Removing a key makes room for one new key, but not two.
+	if err := limited.Delete([]byte("b")); err != nil {
+		t.Error(err.Error())
+	}
+	err = limited.PutBatch([]buckets.Item{
+		{Key: []byte("d"), Value: []byte("1")},
+		{Key: []byte("e"), Value: []byte("1")},
+	})
+	if err != buckets.ErrBucketFull {
+		t.Errorf("got %v, want ErrBucketFull", err)
+	}
+	if ok, _ := cache.Exists([]byte("d")); ok {
+		t.Error("got key from failed batch, want batch rolled back")
+	}
+	err = limited.PutBatch([]buckets.Item{
+		{Key: []byte("d"), Value: []byte("1")},
+		{Key: []byte("c"), Value: []byte("2")},
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// The limit only applies to the limited bucket.
+	if err := cache.Put([]byte("f"), []byte("1")); err != nil {
+		t.Error(err.Error())
+	}
+	if n, _ := cache.Count(); n != 4 {
+		t.Errorf("got %d keys, want 4", n)
+	}
+}
+
+// Ensure inserts that would go over a bucket's limit fail as a whole.
+func TestWithMaxKeysInsert(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	cache, err := bx.New([]byte("cache"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	limited := cache.WithMaxKeys(2)
+	items := []struct{ Key, Value []byte }{
+		{[]byte("a"), []byte("1")},
+		{[]byte("b"), []byte("1")},
+		{[]byte("c"), []byte("1")},
+	}
+	if err := limited.Insert(items); err != buckets.ErrBucketFull {
+		t.Errorf("got %v from Insert, want ErrBucketFull", err)
+	}
+	if err := limited.InsertNX(items); err != buckets.ErrBucketFull {
+		t.Errorf("got %v from InsertNX, want ErrBucketFull", err)
+	}
+	if n, _ := cache.Count(); n != 0 {
+		t.Errorf("got %d keys from failed inserts, want 0", n)
+	}
+
+	if err := limited.Insert(items[:2]); err != nil {
+		t.Error(err.Error())
+	}
+	// Existing keys are skipped by InsertNX, so they don't count.
+	if err := limited.InsertNX(items[:2]); err != nil {
+		t.Error(err.Error())
+	}
+	if err := limited.InsertNX(items); err != buckets.ErrBucketFull {
+		t.Errorf("got %v from InsertNX, want ErrBucketFull", err)
+	}
+}
@@ -324,3 +324,195 @@ func ExampleBucket_MapRange() {
 	// 1995 -> 95
 	// 2000 -> 00
 }
+
+// Ensure that we can select and transform values in a single pass.
+func TestSelectValues(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+		{[]byte("C"), []byte("gamma")},
+	}
+
+	if err := letters.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Select the first letter of each value, skipping key `B`.
+	transform := func(k, v []byte) ([]byte, bool) {
+		if bytes.Equal(k, []byte("B")) {
+			return nil, false
+		}
+		return v[:1], true
+	}
+
+	values, err := letters.SelectValues(transform)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := []string{"a", "g"}
+	if len(values) != len(expected) {
+		t.Fatalf("got %d values, want %d", len(values), len(expected))
+	}
+	for i, want := range expected {
+		if got := values[i]; string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+// Ensure that ForEach stops cleanly on ErrStop and propagates other errors.
+func TestForEach(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+		{[]byte("C"), []byte("gamma")},
+	}
+
+	if err := letters.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Collect keys until we reach `B`.
+	var keys []string
+	do := func(k, v []byte) error {
+		keys = append(keys, string(k))
+		if bytes.Equal(k, []byte("B")) {
+			return buckets.ErrStop
+		}
+		return nil
+	}
+
+	if err := letters.ForEach(do); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 2 || keys[0] != "A" || keys[1] != "B" {
+		t.Errorf("got %q, want [A B]", keys)
+	}
+
+	keys = nil
+	if err := letters.NewPrefixScanner([]byte("B")).ForEach(do); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 1 || keys[0] != "B" {
+		t.Errorf("got %q, want [B]", keys)
+	}
+
+	keys = nil
+	if err := letters.NewRangeScanner([]byte("A"), []byte("C")).ForEach(do); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 2 || keys[0] != "A" || keys[1] != "B" {
+		t.Errorf("got %q, want [A B]", keys)
+	}
+
+	keys = nil
+	if err := letters.NewRangeScanner(nil, nil).ForEachReverse(do); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 2 || keys[0] != "C" || keys[1] != "B" {
+		t.Errorf("got %q, want [C B]", keys)
+	}
+
+	// ForEachKey visits keys alone.
+	keys = nil
+	err = letters.NewPrefixScanner(nil).ForEachKey(func(k []byte) error {
+		return do(k, nil)
+	})
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 2 || keys[0] != "A" || keys[1] != "B" {
+		t.Errorf("got %q, want [A B]", keys)
+	}
+
+	keys = nil
+	err = letters.NewRangeScanner([]byte("B"), nil).ForEachKey(func(k []byte) error {
+		return do(k, nil)
+	})
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 1 || keys[0] != "B" {
+		t.Errorf("got %q, want [B]", keys)
+	}
+
+	keys = nil
+	if err := letters.ForPrefix([]byte("C"), do); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 1 || keys[0] != "C" {
+		t.Errorf("got %q, want [C]", keys)
+	}
+
+	keys = nil
+	if err := letters.ForRange([]byte("B"), []byte("C"), do); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 1 || keys[0] != "B" {
+		t.Errorf("got %q, want [B]", keys)
+	}
+
+	// MapAll passes each item's position, so we can take the first N.
+	keys = nil
+	err = letters.MapAll(func(i int, k, v []byte) error {
+		if i == 2 {
+			return buckets.ErrStop
+		}
+		keys = append(keys, fmt.Sprintf("%d:%s", i, k))
+		return nil
+	})
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	if len(keys) != 2 || keys[0] != "0:A" || keys[1] != "1:B" {
+		t.Errorf("got %q, want [0:A 1:B]", keys)
+	}
+
+	// Any other error aborts iteration and is returned.
+	failed := fmt.Errorf("failed")
+	fail := func(k, v []byte) error {
+		return failed
+	}
+	if err := letters.ForEach(fail); err != failed {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+	if err := letters.NewPrefixScanner(nil).ForEach(fail); err != failed {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+	if err := letters.NewRangeScanner(nil, nil).ForEach(fail); err != failed {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+	if err := letters.ForRange(nil, nil, fail); err != failed {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+	if err := letters.ForPrefix(nil, fail); err != failed {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+	err = letters.MapAll(func(i int, k, v []byte) error {
+		return failed
+	})
+	if err != failed {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+}
@@ -0,0 +1,83 @@
+package buckets
+
+import (
+	"log"
+	"time"
+)
+
+// A Middleware is called around operations on a bucket, e.g., to record
+// their latencies (see WithMiddleware).
+type Middleware interface {
+	// Before is called before operation `op` on key `key`.  For
+	// operations on keys with a prefix, such as PrefixItems or those of
+	// a PrefixScanner, the key is the prefix; for other operations on
+	// several keys, it's nil.
+	Before(op string, key []byte)
+	// After is called once operation `op` on key `key` is done, with the
+	// operation's error, if any, and the time it took.
+	After(op string, key []byte, err error, elapsed time.Duration)
+}
+
+// WithMiddleware returns a copy of the bucket that calls `m` around each
+// operation that reads or writes the bucket, named by method (e.g.,
+// "Get"), including those of its scanners (e.g., "PrefixScanner.Count").
+// Methods that are shorthand for another method, such as PutNX or
+// PutJSON, are observed as that method.  If the bucket already has
+// middleware, `m` is called within it: its Before is called after
+// theirs, and its After before theirs.
+func (bk *Bucket) WithMiddleware(m Middleware) *Bucket {
+	bucket := *bk
+	bucket.mw = append(bk.mw[:len(bk.mw):len(bk.mw)], m)
+	return &bucket
+}
+
+// observe calls the bucket's middleware before operation `op` on key
+// `key`, returning a func to defer with a pointer to the operation's
+// error, which calls the middleware after the operation.
+func (bk *Bucket) observe(op string, key []byte) func(err *error) {
+	if len(bk.mw) == 0 {
+		return func(*error) {}
+	}
+	for _, m := range bk.mw {
+		m.Before(op, key)
+	}
+	start := time.Now()
+	return func(err *error) {
+		elapsed := time.Since(start)
+		for i := len(bk.mw) - 1; i >= 0; i-- {
+			bk.mw[i].After(op, key, *err, elapsed)
+		}
+	}
+}
+
+// LoggingMiddleware logs each operation once it's done, with its error,
+// if any, and the time it took.
+type LoggingMiddleware struct {
+	Logger *log.Logger // if nil, the standard logger is used
+}
+
+// Before does nothing.
+func (LoggingMiddleware) Before(op string, key []byte) {}
+
+// After logs the operation.
+func (lm LoggingMiddleware) After(op string, key []byte, err error, elapsed time.Duration) {
+	logf := log.Printf
+	if lm.Logger != nil {
+		logf = lm.Logger.Printf
+	}
+	if err != nil {
+		logf("buckets: %s %q failed after %s: %s", op, key, elapsed, err)
+		return
+	}
+	logf("buckets: %s %q took %s", op, key, elapsed)
+}
+
+// NoopMiddleware does nothing.  Embed it in a type to implement only
+// one of the Middleware methods.
+type NoopMiddleware struct{}
+
+// Before does nothing.
+func (NoopMiddleware) Before(op string, key []byte) {}
+
+// After does nothing.
+func (NoopMiddleware) After(op string, key []byte, err error, elapsed time.Duration) {}
@@ -0,0 +1,157 @@
+package buckets_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joyrexus/buckets"
+)
+
+// A recordingMiddleware records the calls made to it.
+type recordingMiddleware struct {
+	name  string
+	calls *[]string
+}
+
+func (m recordingMiddleware) Before(op string, key []byte) {
+	*m.calls = append(*m.calls, fmt.Sprintf("%s before %s %s", m.name, op, key))
+}
+
+func (m recordingMiddleware) After(op string, key []byte, err error, elapsed time.Duration) {
+	*m.calls = append(*m.calls, fmt.Sprintf("%s after %s %s %v", m.name, op, key, err))
+}
+
+// Ensure middleware is called around bucket operations.
+func TestWithMiddleware(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var calls []string
+	outer := things.WithMiddleware(recordingMiddleware{"outer", &calls})
+	inner := outer.WithMiddleware(recordingMiddleware{"inner", &calls})
+
+	if err := inner.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := inner.Items(); err != nil {
+		t.Error(err.Error())
+	}
+	want := []string{
+		"outer before Put A",
+		"inner before Put A",
+		"inner after Put A <nil>",
+		"outer after Put A <nil>",
+		"outer before Items ",
+		"inner before Items ",
+		"inner after Items  <nil>",
+		"outer after Items  <nil>",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The original bucket has no middleware.
+	calls = nil
+	if _, err := things.Get([]byte("A")); err != nil {
+		t.Error(err.Error())
+	}
+	if len(calls) != 0 {
+		t.Errorf("got calls %q for bucket without middleware", calls)
+	}
+
+	// Errors are passed to After.
+	failed := fmt.Errorf("failed")
+	outer.UpdateValue([]byte("A"), func([]byte) ([]byte, error) {
+		return nil, failed
+	})
+	if last := calls[len(calls)-1]; last != "outer after UpdateValue A failed" {
+		t.Errorf("got %q, want error passed to After", last)
+	}
+}
+
+// Ensure middleware is called around every read and write, including
+// those of scanners and of shorthand methods.
+func TestWithMiddlewareAllOps(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var calls []string
+	bk := things.WithMiddleware(recordingMiddleware{"mw", &calls})
+
+	if _, err := bk.CompareAndSwap([]byte("A"), nil, []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := bk.PutNX([]byte("B"), []byte("beta")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := bk.NewPrefixScanner([]byte("A")).Count(); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := bk.NewRangeScanner(nil, nil).Keys(); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := bk.Increment([]byte("n"), 1); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := bk.DeletePrefix([]byte("A")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := bk.Clear(); err != nil {
+		t.Error(err.Error())
+	}
+	want := []string{
+		"mw before CompareAndSwap A",
+		"mw after CompareAndSwap A <nil>",
+		"mw before PutIfAbsent B",
+		"mw after PutIfAbsent B <nil>",
+		"mw before PrefixScanner.Count A",
+		"mw after PrefixScanner.Count A <nil>",
+		"mw before RangeScanner.Keys ",
+		"mw after RangeScanner.Keys  <nil>",
+		"mw before Increment n",
+		"mw after Increment n <nil>",
+		"mw before DeletePrefix A",
+		"mw after DeletePrefix A <nil>",
+		"mw before Clear ",
+		"mw after Clear  <nil>",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// Ensure the logging middleware logs operations.
+func TestLoggingMiddleware(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var buf bytes.Buffer
+	logged := things.WithMiddleware(buckets.LoggingMiddleware{Logger: log.New(&buf, "", 0)})
+	if err := logged.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if got := buf.String(); !strings.HasPrefix(got, `buckets: Put "A" took `) {
+		t.Errorf("got log %q", got)
+	}
+
+	var _ buckets.Middleware = buckets.NoopMiddleware{}
+}
@@ -0,0 +1,52 @@
+package buckets
+
+import (
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// An Option configures how Open opens a database.
+type Option func(*options)
+
+// options holds the settings that Options configure.
+type options struct {
+	mode os.FileMode
+	bolt bolt.Options
+}
+
+// ReadOnly opens the database for reading only.  Unlike a database
+// opened for reading and writing, which holds an exclusive lock on the
+// file, any number of processes can open the same file read-only at once
+// (though not while another process has it open for writing).  Methods
+// that write to the database, including New, return ErrReadOnly, so use
+// Bucket to open existing buckets.
+func ReadOnly() Option {
+	return func(o *options) {
+		o.bolt.ReadOnly = true
+	}
+}
+
+// WithTimeout sets how long to wait for a file locked by another process
+// before failing.  A timeout of zero waits indefinitely.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.bolt.Timeout = d
+	}
+}
+
+// WithFileMode sets the mode the database file is created with.
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithBoltOptions sets the options passed to bolt, replacing those set
+// by any earlier options.
+func WithBoltOptions(bo *bolt.Options) Option {
+	return func(o *options) {
+		o.bolt = *bo
+	}
+}
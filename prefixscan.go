@@ -8,32 +8,184 @@ import (
 
 // A PrefixScanner scans a bucket for keys with a given prefix.
 type PrefixScanner struct {
-	db         *DB
+	bk         *Bucket
 	BucketName []byte
 	Prefix     []byte
+	limit      int
+	offset     int
+	reverse    bool
+	start      []byte // set by SeekFrom
+	filter     func(k, v []byte) bool
+	err        error // set by Stream
 }
 
-// Map applies `do` on each key/value pair for keys with prefix.
-func (ps *PrefixScanner) Map(do func(k, v []byte) error) error {
+// WithLimit returns a copy of the scanner that scans at most `n` keys.
+// A limit of zero means no limit.
+func (ps *PrefixScanner) WithLimit(n int) *PrefixScanner {
+	scanner := *ps
+	scanner.limit = n
+	return &scanner
+}
+
+// WithOffset returns a copy of the scanner that skips the first `n` keys
+// with prefix.
+func (ps *PrefixScanner) WithOffset(n int) *PrefixScanner {
+	scanner := *ps
+	scanner.offset = n
+	return &scanner
+}
+
+// SeekFrom returns a copy of the scanner that starts scanning at key
+// `start`, positioning its cursor there rather than at the first key with
+// prefix.  Keys with prefix that come before `start` (or after it, when
+// scanning in reverse) are skipped without being visited, so unlike
+// WithOffset, seeking costs the same wherever `start` is.  A nil `start`
+// scans all keys with prefix.  For keyset pagination, start each page
+// just after the last key of the previous page, at that key followed by
+// a zero byte, and limit the page size with WithLimit.
+func (ps *PrefixScanner) SeekFrom(start []byte) *PrefixScanner {
+	scanner := *ps
+	scanner.start = start
+	return &scanner
+}
+
+// WithFilter returns a copy of the scanner that only scans keys with
+// prefix for which `fn` returns true.  The scanner's offset and limit
+// apply to the keys that pass the filter.  Since `fn` is called within
+// the scan's read-only transaction, it mustn't start another transaction
+// (e.g., by calling another method of the bucket).  The key and value
+// passed to `fn` are only valid while it runs.
+func (ps *PrefixScanner) WithFilter(fn func(k, v []byte) bool) *PrefixScanner {
+	scanner := *ps
+	scanner.filter = fn
+	return &scanner
+}
+
+// Reverse returns a copy of the scanner that scans keys in descending
+// rather than ascending order.
+func (ps *PrefixScanner) Reverse() *PrefixScanner {
+	scanner := *ps
+	scanner.reverse = !ps.reverse
+	return &scanner
+}
+
+// scan applies `do` on each key/value pair for keys with prefix,
+// honoring the scanner's offset and limit.  The scan stops early if
+// `do` returns an error.
+func (ps *PrefixScanner) scan(tx *bolt.Tx, do func(k, v []byte) error) error {
+	return ps.walk(tx, func(k, v []byte) error {
+		v, err := ps.bk.decode(v)
+		if err != nil {
+			return err
+		}
+		return do(k, v)
+	})
+}
+
+// walk is like scan, but applies `do` on each key and value as stored,
+// without decoding the value.  Scans that only need keys use walk so
+// that values aren't read.
+func (ps *PrefixScanner) walk(tx *bolt.Tx, do func(k, v []byte) error) error {
 	pre := ps.Prefix
-	return ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
-		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, _ = c.Next() {
-			do(k, v)
+	c := ps.bk.cursor(tx)
+	seek := pre
+	if ps.start != nil && bytes.Compare(ps.start, pre) > 0 {
+		seek = ps.start
+	}
+	k, v := c.Seek(seek)
+	next := c.Next
+	if ps.reverse {
+		end := prefixEnd(pre)
+		if ps.start != nil && (end == nil || bytes.Compare(ps.start, end) < 0) {
+			k, v = seekLast(c, ps.start, true)
+		} else {
+			k, v = seekLast(c, end, false)
+		}
+		next = c.Prev
+	}
+	skipped, scanned := 0, 0
+	for ; hasPrefix(k, pre); k, v = next() {
+		if ps.limit > 0 && scanned >= ps.limit {
+			break
+		}
+		if ps.filter != nil {
+			v, err := ps.bk.decode(v)
+			if err != nil {
+				return err
+			}
+			if !ps.filter(k, v) {
+				continue
+			}
+		}
+		if skipped < ps.offset {
+			skipped++
+			continue
 		}
+		if err := do(k, v); err != nil {
+			return err
+		}
+		scanned++
+	}
+	return nil
+}
+
+// Map applies `do` on each key/value pair for keys with prefix.
+func (ps *PrefixScanner) Map(do func(k, v []byte) error) (err error) {
+	defer ps.bk.observe("PrefixScanner.Map", ps.Prefix)(&err)
+	return ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.scan(tx, func(k, v []byte) error {
+			do(k, v)
+			return nil
+		})
+	})
+}
+
+// ForEach applies `do` on each key/value pair for keys with prefix,
+// stopping at the first error returned by `do`.  If that error is
+// ErrStop, ForEach returns nil; otherwise, it returns the error.  The key
+// and value passed to `do` are only valid while it runs.
+func (ps *PrefixScanner) ForEach(do func(k, v []byte) error) (err error) {
+	defer ps.bk.observe("PrefixScanner.ForEach", ps.Prefix)(&err)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.scan(tx, do)
+	})
+	if err == ErrStop {
 		return nil
+	}
+	return err
+}
+
+// ForEachFiltered applies `do` on each key/value pair for keys with
+// prefix for which `filter` returns true, like ForEach.  See WithFilter
+// for the constraints on `filter`.
+func (ps *PrefixScanner) ForEachFiltered(filter func(k, v []byte) bool, do func(k, v []byte) error) error {
+	return ps.WithFilter(filter).ForEach(do)
+}
+
+// ForEachKey applies `do` on each key with prefix, like ForEach, but
+// without reading the values.  The key passed to `do` is only valid while
+// it runs.
+func (ps *PrefixScanner) ForEachKey(do func(k []byte) error) (err error) {
+	defer ps.bk.observe("PrefixScanner.ForEachKey", ps.Prefix)(&err)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.walk(tx, func(k, _ []byte) error {
+			return do(k)
+		})
 	})
+	if err == ErrStop {
+		return nil
+	}
+	return err
 }
 
 // Count returns a count of the keys with prefix.
 func (ps *PrefixScanner) Count() (count int, err error) {
-	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
-		for k, _ := c.Seek(pre); bytes.HasPrefix(k, pre); k, _ = c.Next() {
+	defer ps.bk.observe("PrefixScanner.Count", ps.Prefix)(&err)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.walk(tx, func(k, _ []byte) error {
 			count++
-		}
-		return nil
+			return nil
+		})
 	})
 	if err != nil {
 		return count, err
@@ -41,15 +193,16 @@ func (ps *PrefixScanner) Count() (count int, err error) {
 	return count, err
 }
 
-// Keys returns a slice of keys with prefix.
+// Keys returns a slice of keys with prefix.  The values aren't read,
+// so this is faster than Items for buckets with large values.  Like
+// Items, the keys are copies.
 func (ps *PrefixScanner) Keys() (keys [][]byte, err error) {
-	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
-		for k, _ := c.Seek(pre); bytes.HasPrefix(k, pre); k, _ = c.Next() {
+	defer ps.bk.observe("PrefixScanner.Keys", ps.Prefix)(&err)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.walk(tx, copiedKeys(func(k []byte) error {
 			keys = append(keys, k)
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -57,15 +210,15 @@ func (ps *PrefixScanner) Keys() (keys [][]byte, err error) {
 	return keys, err
 }
 
-// Values returns a slice of values for keys with prefix.
+// Values returns a slice of values for keys with prefix.  Like Items,
+// the values are copies.
 func (ps *PrefixScanner) Values() (values [][]byte, err error) {
-	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
-		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
+	defer ps.bk.observe("PrefixScanner.Values", ps.Prefix)(&err)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.scan(tx, copied(func(_, v []byte) error {
 			values = append(values, v)
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -73,15 +226,16 @@ func (ps *PrefixScanner) Values() (values [][]byte, err error) {
 	return values, err
 }
 
-// Items returns a slice of key/value pairs for keys with prefix.
+// Items returns a slice of key/value pairs for keys with prefix.  The
+// keys and values are copies, so they're safe to use after the
+// transaction.
 func (ps *PrefixScanner) Items() (items []Item, err error) {
-	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
-		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
+	defer ps.bk.observe("PrefixScanner.Items", ps.Prefix)(&err)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.scan(tx, copied(func(k, v []byte) error {
 			items = append(items, Item{k, v})
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -89,17 +243,133 @@ func (ps *PrefixScanner) Items() (items []Item, err error) {
 	return items, err
 }
 
+// ItemsReverse returns a slice of key/value pairs for keys with prefix,
+// in descending key order.
+func (ps *PrefixScanner) ItemsReverse() ([]Item, error) {
+	scanner := *ps
+	scanner.reverse = true
+	return scanner.Items()
+}
+
+// ForEachReverse applies `do` on each key/value pair for keys with prefix,
+// in descending key order.  Otherwise, it's like ForEach.
+func (ps *PrefixScanner) ForEachReverse(do func(k, v []byte) error) error {
+	scanner := *ps
+	scanner.reverse = true
+	return scanner.ForEach(do)
+}
+
+// First returns the key/value pair for the first key with prefix, in
+// byte-sorted order, ignoring the scanner's start, offset, limit, and
+// direction.  Only the first key is read.  If there are no keys with
+// prefix, First returns ErrEmpty.
+func (ps *PrefixScanner) First() (item Item, err error) {
+	defer ps.bk.observe("PrefixScanner.First", ps.Prefix)(&err)
+	return ps.bounds().one()
+}
+
+// Last returns the key/value pair for the last key with prefix, in
+// byte-sorted order, ignoring the scanner's start, offset, limit, and
+// direction.  Only the last key is read.  If there are no keys with
+// prefix, Last returns ErrEmpty.
+func (ps *PrefixScanner) Last() (item Item, err error) {
+	defer ps.bk.observe("PrefixScanner.Last", ps.Prefix)(&err)
+	scanner := ps.bounds()
+	scanner.reverse = true
+	return scanner.one()
+}
+
+// bounds returns a copy of the scanner without its start, offset, limit,
+// and direction.
+func (ps *PrefixScanner) bounds() *PrefixScanner {
+	return &PrefixScanner{
+		bk:         ps.bk,
+		BucketName: ps.BucketName,
+		Prefix:     ps.Prefix,
+		filter:     ps.filter,
+	}
+}
+
+// one returns the first key/value pair scanned, or ErrEmpty.
+func (ps *PrefixScanner) one() (item Item, err error) {
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		err := ps.scan(tx, func(k, v []byte) error {
+			item = Item{make([]byte, len(k)), make([]byte, len(v))}
+			copy(item.Key, k)
+			copy(item.Value, v)
+			return ErrStop
+		})
+		if err == ErrStop {
+			return nil
+		}
+		if err == nil {
+			return ErrEmpty
+		}
+		return err
+	})
+	if err != nil {
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Page returns a slice of at most `limit` key/value pairs for keys with prefix,
+// skipping the first `offset` keys.  The scan stops as soon as the page is
+// full.  If `offset` is past the last key, the returned slice is empty but
+// not nil.
+func (ps *PrefixScanner) Page(offset, limit int) ([]Item, error) {
+	items, err := ps.WithOffset(offset).WithLimit(limit).Items()
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []Item{}
+	}
+	return items, nil
+}
+
+// ItemsFrom returns a page of at most `limit` key/value pairs for keys
+// with prefix, starting at key `start` (see SeekFrom), along with the
+// key to start the next page at.  The next key is nil once there are no
+// more pages.  If `start` is past the last key, the returned slice is
+// empty but not nil.  Unlike Page, ItemsFrom seeks directly to the start
+// of the page, so pages deep into the scan cost no more than the first.
+// A nil `start` starts at the first key with prefix, and a limit of zero
+// means no limit.  The scanner's offset is ignored.
+func (ps *PrefixScanner) ItemsFrom(start []byte, limit int) (items []Item, next []byte, err error) {
+	scanner := *ps
+	scanner.start = start
+	scanner.offset = 0
+	scanner.limit = 0
+	if limit > 0 {
+		scanner.limit = limit + 1 // peek at the start of the next page
+	}
+	items, err = scanner.Items()
+	if err != nil {
+		return nil, nil, err
+	}
+	if limit > 0 && len(items) > limit {
+		next = make([]byte, len(items[limit].Key))
+		copy(next, items[limit].Key)
+		items = items[:limit]
+	}
+	if items == nil {
+		items = []Item{}
+	}
+	return items, next, nil
+}
+
 // ItemMapping returns a map of key/value pairs for keys with prefix.
-// This only works with buckets whose keys are byte-sliced strings.
-func (ps *PrefixScanner) ItemMapping() (map[string][]byte, error) {
-	pre := ps.Prefix
-	items := make(map[string][]byte)
-	err := ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
-		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
+// This only works with buckets whose keys are byte-sliced strings.  The
+// values are copies, so they're safe to use after the transaction.
+func (ps *PrefixScanner) ItemMapping() (items map[string][]byte, err error) {
+	defer ps.bk.observe("PrefixScanner.ItemMapping", ps.Prefix)(&err)
+	items = make(map[string][]byte)
+	err = ps.bk.view(func(tx *bolt.Tx) error {
+		return ps.scan(tx, copied(func(k, v []byte) error {
 			items[string(k)] = v
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -2,7 +2,11 @@ package buckets_test
 
 import (
 	"bytes"
+	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/joyrexus/buckets"
 )
 
 // Ensure we can scan prefixes.
@@ -80,6 +84,13 @@ func TestPrefixScanner(t *testing.T) {
 	// get k/v pairs for keys with `foo` prefix
 	items, err := foo.Items()
 
+	if len(items) != len(wantItems) {
+		t.Fatalf("got %d items, want %d", len(items), len(wantItems))
+	}
+	if got, want := items[0], buckets.NewItem([]byte("foo/"), []byte("foo")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
 	for i, want := range wantItems {
 		got := items[i]
 		if !bytes.Equal(got.Key, want.Key) {
@@ -113,7 +124,496 @@ func TestPrefixScanner(t *testing.T) {
 		}
 	}
 
+	// The mapped values are copies, safe to modify.
+	gotMapping["foo/"][0] = 'x'
+	if v, _ := paths.Get([]byte("foo/")); !bytes.Equal(v, wantMapping["foo/"]) {
+		t.Errorf("got %s after modifying mapped value, want %s", v, wantMapping["foo/"])
+	}
+
+	// So are the keys and values.
+	keys, err = foo.Keys()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	values, err = foo.Values()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	keys[0][0], values[0][0] = 'x', 'x'
+	if v, _ := paths.Get([]byte("foo/")); !bytes.Equal(v, wantMapping["foo/"]) {
+		t.Errorf("got %s after modifying keys and values, want %s", v, wantMapping["foo/"])
+	}
+
 	if err = bx.Delete([]byte("paths")); err != nil {
 		t.Error(err.Error())
 	}
 }
+
+// Ensure we can limit and offset prefix scans.
+func TestPrefixScannerLimitOffset(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	pathItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("foo/"), []byte("foo")},
+		{[]byte("foo/a/"), []byte("a")},
+		{[]byte("foo/b/"), []byte("b")},
+		{[]byte("foo/c/"), []byte("c")},
+		{[]byte("good/"), []byte("")},
+	}
+
+	if err = paths.Insert(pathItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	foo := paths.NewPrefixScanner([]byte("foo/"))
+
+	tests := []struct {
+		offset, limit int
+		want          []string
+	}{
+		{0, 0, []string{"foo/", "foo/a/", "foo/b/", "foo/c/"}},
+		{0, 2, []string{"foo/", "foo/a/"}},
+		{1, 2, []string{"foo/a/", "foo/b/"}},
+		{3, 2, []string{"foo/c/"}},
+		{5, 2, []string{}},
+	}
+
+	for _, tt := range tests {
+		scanner := foo.WithOffset(tt.offset).WithLimit(tt.limit)
+
+		count, err := scanner.Count()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if count != len(tt.want) {
+			t.Errorf("offset %d, limit %d: got count %d, want %d",
+				tt.offset, tt.limit, count, len(tt.want))
+		}
+
+		keys, err := scanner.Keys()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("offset %d, limit %d: got %d keys, want %d",
+				tt.offset, tt.limit, len(keys), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if got := keys[i]; string(got) != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		}
+	}
+
+	// The original scanner should be unaffected by the options.
+	count, err := foo.Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 4 {
+		t.Errorf("got count %d, want %d", count, 4)
+	}
+}
+
+// Ensure we can scan prefixes in reverse order.
+func TestPrefixScannerReverse(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	pathItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("fo/"), []byte("")},
+		{[]byte("foo/"), []byte("foo")},
+		{[]byte("foo/a/"), []byte("a")},
+		{[]byte("foo/b/"), []byte("b")},
+		{[]byte("foo0"), []byte("")},
+		{[]byte("good/"), []byte("")},
+	}
+
+	if err = paths.Insert(pathItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"foo/", []string{"foo/b/", "foo/a/", "foo/"}},
+		{"good/", []string{"good/"}},
+		{"", []string{"good/", "foo0", "foo/b/", "foo/a/", "foo/", "fo/"}},
+		{"zzz", []string{}},
+	}
+
+	for _, tt := range tests {
+		keys, err := paths.NewPrefixScanner([]byte(tt.prefix)).Reverse().Keys()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("prefix %q: got %d keys, want %d", tt.prefix, len(keys), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if got := keys[i]; string(got) != want {
+				t.Errorf("prefix %q: got %s, want %s", tt.prefix, got, want)
+			}
+		}
+	}
+
+	// Reverse scans honor limits, e.g., for the latest N items.
+	keys, err := paths.NewPrefixScanner([]byte("foo/")).Reverse().WithLimit(2).Keys()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(keys) != 2 || string(keys[0]) != "foo/b/" || string(keys[1]) != "foo/a/" {
+		t.Errorf("got %q, want [foo/b/ foo/a/]", keys)
+	}
+
+	items, err := paths.NewPrefixScanner([]byte("foo/")).ItemsReverse()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 3 || string(items[0].Key) != "foo/b/" || string(items[2].Key) != "foo/" {
+		t.Errorf("got %q, want items for foo/b/, foo/a/, foo/", items)
+	}
+
+	// ForEachReverse visits the last key first and can stop early.
+	var visited []string
+	err = paths.NewPrefixScanner([]byte("foo/")).ForEachReverse(func(k, _ []byte) error {
+		visited = append(visited, string(k))
+		return buckets.ErrStop
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(visited) != 1 || visited[0] != "foo/b/" {
+		t.Errorf("got %q, want [foo/b/]", visited)
+	}
+}
+
+// Ensure prefix scans can start from a given key.
+func TestPrefixScannerSeekFrom(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	pathItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("fo/"), []byte("")},
+		{[]byte("foo/"), []byte("foo")},
+		{[]byte("foo/a/"), []byte("a")},
+		{[]byte("foo/b/"), []byte("b")},
+		{[]byte("foo/c/"), []byte("c")},
+		{[]byte("foo0"), []byte("")},
+	}
+
+	if err = paths.Insert(pathItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		start   string
+		reverse bool
+		want    []string
+	}{
+		{"foo/a/", false, []string{"foo/a/", "foo/b/", "foo/c/"}},
+		{"foo/aa", false, []string{"foo/b/", "foo/c/"}},
+		{"a", false, []string{"foo/", "foo/a/", "foo/b/", "foo/c/"}},
+		{"z", false, []string{}},
+		{"foo/b/", true, []string{"foo/b/", "foo/a/", "foo/"}},
+		{"foo/bb", true, []string{"foo/b/", "foo/a/", "foo/"}},
+		{"z", true, []string{"foo/c/", "foo/b/", "foo/a/", "foo/"}},
+		{"a", true, []string{}},
+	}
+
+	for _, tt := range tests {
+		scanner := paths.NewPrefixScanner([]byte("foo/")).SeekFrom([]byte(tt.start))
+		if tt.reverse {
+			scanner = scanner.Reverse()
+		}
+		keys, err := scanner.Keys()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("start %q: got %q, want %q", tt.start, keys, tt.want)
+			continue
+		}
+		for i, want := range tt.want {
+			if got := keys[i]; string(got) != want {
+				t.Errorf("start %q: got %s, want %s", tt.start, got, want)
+			}
+		}
+	}
+
+	// Page through all keys, starting each page after the last key seen.
+	var pages [][]string
+	var start []byte
+	for {
+		keys, err := paths.Seek(start).WithLimit(4).Keys()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(keys) == 0 {
+			break
+		}
+		var page []string
+		for _, k := range keys {
+			page = append(page, string(k))
+		}
+		pages = append(pages, page)
+		start = append(keys[len(keys)-1], 0)
+	}
+	want := [][]string{
+		{"fo/", "foo/", "foo/a/", "foo/b/"},
+		{"foo/c/", "foo0"},
+	}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("got pages %q, want %q", pages, want)
+	}
+}
+
+// Ensure we can page through prefix scans with resumable start keys.
+func TestPrefixScannerItemsFrom(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	for _, k := range []string{"fo/", "foo/a", "foo/b", "foo/c", "foo/d", "foo/e", "foo0"} {
+		if err := paths.Put([]byte(k), []byte(k)); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	foo := paths.NewPrefixScanner([]byte("foo/"))
+	var pages [][]string
+	var start []byte
+	for {
+		items, next, err := foo.ItemsFrom(start, 2)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var page []string
+		for _, item := range items {
+			page = append(page, string(item.Key))
+		}
+		pages = append(pages, page)
+		if next == nil {
+			break
+		}
+		start = next
+	}
+	want := [][]string{
+		{"foo/a", "foo/b"},
+		{"foo/c", "foo/d"},
+		{"foo/e"},
+	}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("got pages %q, want %q", pages, want)
+	}
+
+	// A start past the last key gives an empty page and no next key.
+	items, next, err := foo.ItemsFrom([]byte("foo/z"), 2)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if items == nil || len(items) != 0 || next != nil {
+		t.Errorf("got %q and next %q, want empty page", items, next)
+	}
+
+	// Pages can also run in reverse.
+	items, next, err = foo.Reverse().ItemsFrom(nil, 3)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 3 || string(items[0].Key) != "foo/e" || string(next) != "foo/b" {
+		t.Errorf("got %q and next %q, want foo/e to foo/c, then foo/b", items, next)
+	}
+
+	// Pages are copies, safe to keep and modify after later writes.
+	for i := 0; i < 1000; i++ {
+		k := []byte(fmt.Sprintf("bar/%04d", i))
+		if err := paths.Put(k, bytes.Repeat([]byte("v"), 1000)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	items[0].Key[0], items[0].Value[0] = 'x', 'x'
+	if v, _ := paths.Get([]byte("foo/e")); string(v) != "foo/e" {
+		t.Errorf("got %q after modifying page, want %q", v, "foo/e")
+	}
+}
+
+// Ensure we can page through prefix scans.
+func TestPrefixScannerPage(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	pathItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("foo/a/"), []byte("a")},
+		{[]byte("foo/b/"), []byte("b")},
+		{[]byte("foo/c/"), []byte("c")},
+		{[]byte("good/"), []byte("")},
+	}
+
+	if err = paths.Insert(pathItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	foo := paths.NewPrefixScanner([]byte("foo/"))
+
+	page, err := foo.Page(2, 2)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(page) != 1 || string(page[0].Key) != "foo/c/" {
+		t.Errorf("got %v, want page with key foo/c/", page)
+	}
+
+	// An offset past the last key gives an empty, non-nil page.
+	page, err = foo.Page(10, 2)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if page == nil || len(page) != 0 {
+		t.Errorf("got %#v, want empty page", page)
+	}
+}
+
+// Ensure we can get the first and last items with prefix.
+func TestPrefixScannerFirstLast(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	logs, err := bx.New([]byte("logs"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("app/2015-01-02"), []byte("start")},
+		{[]byte("app/2015-03-04"), []byte("crash")},
+		{[]byte("app/2015-05-06"), []byte("stop")},
+		{[]byte("db/2014-12-31"), []byte("init")},
+	}
+	if err := logs.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	// The scanner's offset, limit, and direction are ignored.
+	app := logs.NewPrefixScanner([]byte("app/")).WithOffset(1).WithLimit(1).Reverse()
+
+	first, err := app.First()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if string(first.Key) != "app/2015-01-02" || string(first.Value) != "start" {
+		t.Errorf("got first %q, want app/2015-01-02", first)
+	}
+
+	last, err := app.Last()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if string(last.Key) != "app/2015-05-06" || string(last.Value) != "stop" {
+		t.Errorf("got last %q, want app/2015-05-06", last)
+	}
+
+	for _, do := range []func() (buckets.Item, error){
+		logs.NewPrefixScanner([]byte("web/")).First,
+		logs.NewPrefixScanner([]byte("web/")).Last,
+	} {
+		if _, err := do(); err != buckets.ErrEmpty {
+			t.Errorf("got %v, want ErrEmpty", err)
+		}
+	}
+}
+
+// Ensure prefix scans can be filtered before they're limited.
+func TestPrefixScannerFilter(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	paths, err := bx.New([]byte("paths"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	pathItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("foo/a"), []byte("draft")},
+		{[]byte("foo/b"), []byte("published")},
+		{[]byte("foo/c"), []byte("draft")},
+		{[]byte("foo/d"), []byte("published")},
+		{[]byte("foo/e"), []byte("published")},
+		{[]byte("goo/a"), []byte("published")},
+	}
+	if err = paths.Insert(pathItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	published := func(k, v []byte) bool {
+		return string(v) == "published"
+	}
+	foo := paths.NewPrefixScanner([]byte("foo/")).WithFilter(published)
+
+	count, err := foo.Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 3 {
+		t.Errorf("got count %d, want 3", count)
+	}
+
+	items, err := foo.WithOffset(1).WithLimit(1).Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 1 || string(items[0].Key) != "foo/d" {
+		t.Errorf("got %q, want item for foo/d", items)
+	}
+
+	var keys []string
+	err = paths.NewPrefixScanner([]byte("foo/")).ForEachFiltered(published, func(k, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if fmt.Sprint(keys) != "[foo/b foo/d foo/e]" {
+		t.Errorf("got %q, want [foo/b foo/d foo/e]", keys)
+	}
+}
@@ -1,34 +1,171 @@
 package buckets
 
-import "github.com/boltdb/bolt"
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+)
 
 // A RangeScanner scans a bucket for keys within a given range.
 type RangeScanner struct {
-	db         *DB
+	bk         *Bucket
 	BucketName []byte
 	Min        []byte
 	Max        []byte
+	limit      int
+	offset     int
+	reverse    bool
+	excludeMin bool
+	excludeMax bool
+}
+
+// WithLimit returns a copy of the scanner that scans at most `n` keys.
+// A limit of zero means no limit.
+func (rs *RangeScanner) WithLimit(n int) *RangeScanner {
+	scanner := *rs
+	scanner.limit = n
+	return &scanner
+}
+
+// WithOffset returns a copy of the scanner that skips the first `n` keys
+// within the range.
+func (rs *RangeScanner) WithOffset(n int) *RangeScanner {
+	scanner := *rs
+	scanner.offset = n
+	return &scanner
+}
+
+// Reverse returns a copy of the scanner that scans keys in descending
+// rather than ascending order.
+func (rs *RangeScanner) Reverse() *RangeScanner {
+	scanner := *rs
+	scanner.reverse = !rs.reverse
+	return &scanner
+}
+
+// ExcludeMin returns a copy of the scanner whose range excludes its `min`
+// key.  For stable paging, pass the last key of one page as the `min` of
+// a scanner for the next page, excluding it.
+func (rs *RangeScanner) ExcludeMin() *RangeScanner {
+	scanner := *rs
+	scanner.excludeMin = true
+	return &scanner
+}
+
+// ExcludeMax returns a copy of the scanner whose range excludes its `max`
+// key.
+func (rs *RangeScanner) ExcludeMax() *RangeScanner {
+	scanner := *rs
+	scanner.excludeMax = true
+	return &scanner
+}
+
+// scan applies `do` on each key/value pair for keys within range,
+// honoring the scanner's offset and limit.  The scan stops early if
+// `do` returns an error.
+func (rs *RangeScanner) scan(tx *bolt.Tx, do func(k, v []byte) error) error {
+	return rs.walk(tx, func(k, v []byte) error {
+		v, err := rs.bk.decode(v)
+		if err != nil {
+			return err
+		}
+		return do(k, v)
+	})
+}
+
+// walk is like scan, but applies `do` on each key and value as stored,
+// without decoding the value.  Scans that only need keys use walk so
+// that values aren't read.
+func (rs *RangeScanner) walk(tx *bolt.Tx, do func(k, v []byte) error) error {
+	c := rs.bk.cursor(tx)
+	k, v := c.Seek(rs.Min)
+	if rs.excludeMin && k != nil && bytes.Equal(k, rs.Min) {
+		k, v = c.Next()
+	}
+	next, inRange := c.Next, func(k []byte) bool {
+		return isBefore(k, rs.Max) && !(rs.excludeMax && bytes.Equal(k, rs.Max))
+	}
+	if rs.reverse {
+		k, v = seekLast(c, rs.Max, !rs.excludeMax)
+		next, inRange = c.Prev, func(k []byte) bool {
+			return isAfter(k, rs.Min) && !(rs.excludeMin && bytes.Equal(k, rs.Min))
+		}
+	}
+	skipped, scanned := 0, 0
+	for ; inRange(k); k, v = next() {
+		if rs.limit > 0 && scanned >= rs.limit {
+			break
+		}
+		if skipped < rs.offset {
+			skipped++
+			continue
+		}
+		if err := do(k, v); err != nil {
+			return err
+		}
+		scanned++
+	}
+	return nil
 }
 
 // Map applies `do` on each key/value pair for keys within range.
-func (rs *RangeScanner) Map(do func(k, v []byte) error) error {
-	return rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
+func (rs *RangeScanner) Map(do func(k, v []byte) error) (err error) {
+	defer rs.bk.observe("RangeScanner.Map", nil)(&err)
+	return rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.scan(tx, func(k, v []byte) error {
 			do(k, v)
-		}
+			return nil
+		})
+	})
+}
+
+// ForEach applies `do` on each key/value pair for keys within the range,
+// stopping at the first error returned by `do`.  If that error is
+// ErrStop, ForEach returns nil; otherwise, it returns the error.  The key
+// and value passed to `do` are only valid while it runs.
+func (rs *RangeScanner) ForEach(do func(k, v []byte) error) (err error) {
+	defer rs.bk.observe("RangeScanner.ForEach", nil)(&err)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.scan(tx, do)
+	})
+	if err == ErrStop {
 		return nil
+	}
+	return err
+}
+
+// ForEachReverse applies `do` on each key/value pair for keys within the
+// range, in descending key order.  Otherwise, it's like ForEach.
+func (rs *RangeScanner) ForEachReverse(do func(k, v []byte) error) error {
+	scanner := *rs
+	scanner.reverse = true
+	return scanner.ForEach(do)
+}
+
+// ForEachKey applies `do` on each key within the range, like ForEach, but
+// without reading the values.  The key passed to `do` is only valid while
+// it runs.
+func (rs *RangeScanner) ForEachKey(do func(k []byte) error) (err error) {
+	defer rs.bk.observe("RangeScanner.ForEachKey", nil)(&err)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.walk(tx, func(k, _ []byte) error {
+			return do(k)
+		})
 	})
+	if err == ErrStop {
+		return nil
+	}
+	return err
 }
 
 // Count returns a count of the keys within the range.
 func (rs *RangeScanner) Count() (count int, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, _ := c.Seek(rs.Min); isBefore(k, rs.Max); k, _ = c.Next() {
+	defer rs.bk.observe("RangeScanner.Count", nil)(&err)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.walk(tx, func(k, _ []byte) error {
 			count++
-		}
-		return nil
+			return nil
+		})
 	})
 	if err != nil {
 		return count, err
@@ -36,14 +173,16 @@ func (rs *RangeScanner) Count() (count int, err error) {
 	return count, err
 }
 
-// Keys returns a slice of keys within the range.
+// Keys returns a slice of keys within the range.  The values aren't
+// read, so this is faster than Items for buckets with large values.
+// Like Items, the keys are copies.
 func (rs *RangeScanner) Keys() (keys [][]byte, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, _ := c.Seek(rs.Min); isBefore(k, rs.Max); k, _ = c.Next() {
+	defer rs.bk.observe("RangeScanner.Keys", nil)(&err)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.walk(tx, copiedKeys(func(k []byte) error {
 			keys = append(keys, k)
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -51,14 +190,15 @@ func (rs *RangeScanner) Keys() (keys [][]byte, err error) {
 	return keys, err
 }
 
-// Values returns a slice of values for keys within the range.
+// Values returns a slice of values for keys within the range.  Like
+// Items, the values are copies.
 func (rs *RangeScanner) Values() (values [][]byte, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
+	defer rs.bk.observe("RangeScanner.Values", nil)(&err)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.scan(tx, copied(func(_, v []byte) error {
 			values = append(values, v)
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -67,14 +207,15 @@ func (rs *RangeScanner) Values() (values [][]byte, err error) {
 }
 
 // Items returns a slice of key/value pairs for keys within the range.
-// Note that the returned slice contains elements of type Item.
+// Note that the returned slice contains elements of type Item.  The keys
+// and values are copies, so they're safe to use after the transaction.
 func (rs *RangeScanner) Items() (items []Item, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
+	defer rs.bk.observe("RangeScanner.Items", nil)(&err)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.scan(tx, copied(func(k, v []byte) error {
 			items = append(items, Item{k, v})
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -82,16 +223,96 @@ func (rs *RangeScanner) Items() (items []Item, err error) {
 	return items, err
 }
 
+// ItemsReverse returns a slice of key/value pairs for keys within the
+// range, in descending key order.
+func (rs *RangeScanner) ItemsReverse() ([]Item, error) {
+	scanner := *rs
+	scanner.reverse = true
+	return scanner.Items()
+}
+
+// First returns the key/value pair for the first key within the range, in
+// byte-sorted order, ignoring the scanner's offset, limit, and direction.
+// Only the first key is read.  If there are no keys within the range, First
+// returns ErrEmpty.
+func (rs *RangeScanner) First() (item Item, err error) {
+	defer rs.bk.observe("RangeScanner.First", nil)(&err)
+	return rs.bounds().one()
+}
+
+// Last returns the key/value pair for the last key within the range, in
+// byte-sorted order, ignoring the scanner's offset, limit, and direction.
+// Only the last key is read.  If there are no keys within the range, Last
+// returns ErrEmpty.
+func (rs *RangeScanner) Last() (item Item, err error) {
+	defer rs.bk.observe("RangeScanner.Last", nil)(&err)
+	scanner := rs.bounds()
+	scanner.reverse = true
+	return scanner.one()
+}
+
+// bounds returns a copy of the scanner without its offset, limit, and
+// direction.
+func (rs *RangeScanner) bounds() *RangeScanner {
+	return &RangeScanner{
+		bk:         rs.bk,
+		BucketName: rs.BucketName,
+		Min:        rs.Min,
+		Max:        rs.Max,
+		excludeMin: rs.excludeMin,
+		excludeMax: rs.excludeMax,
+	}
+}
+
+// one returns the first key/value pair scanned, or ErrEmpty.
+func (rs *RangeScanner) one() (item Item, err error) {
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		err := rs.scan(tx, func(k, v []byte) error {
+			item = Item{make([]byte, len(k)), make([]byte, len(v))}
+			copy(item.Key, k)
+			copy(item.Value, v)
+			return ErrStop
+		})
+		if err == ErrStop {
+			return nil
+		}
+		if err == nil {
+			return ErrEmpty
+		}
+		return err
+	})
+	if err != nil {
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Page returns a slice of at most `limit` key/value pairs for keys within the range,
+// skipping the first `offset` keys.  The scan stops as soon as the page is
+// full.  If `offset` is past the last key, the returned slice is empty but
+// not nil.
+func (rs *RangeScanner) Page(offset, limit int) ([]Item, error) {
+	items, err := rs.WithOffset(offset).WithLimit(limit).Items()
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []Item{}
+	}
+	return items, nil
+}
+
 // ItemMapping returns a map of key/value pairs for keys within the range.
-// This only works with buckets whose keys are byte-sliced strings.
-func (rs *RangeScanner) ItemMapping() (map[string][]byte, error) {
-	items := make(map[string][]byte)
-	err := rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
+// This only works with buckets whose keys are byte-sliced strings.  The
+// values are copies, so they're safe to use after the transaction.
+func (rs *RangeScanner) ItemMapping() (items map[string][]byte, err error) {
+	defer rs.bk.observe("RangeScanner.ItemMapping", nil)(&err)
+	items = make(map[string][]byte)
+	err = rs.bk.view(func(tx *bolt.Tx) error {
+		return rs.scan(tx, copied(func(k, v []byte) error {
 			items[string(k)] = v
-		}
-		return nil
+			return nil
+		}))
 	})
 	if err != nil {
 		return nil, err
@@ -2,7 +2,11 @@ package buckets_test
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/joyrexus/buckets"
 )
 
 // Ensures we can scan ranges.
@@ -123,7 +127,349 @@ func TestRangeScanner(t *testing.T) {
 		}
 	}
 
+	// The mapped values are copies, safe to modify.
+	gotMapping["1990"][0] = 'x'
+	if v, _ := years.Get([]byte("1990")); !bytes.Equal(v, wantMapping["1990"]) {
+		t.Errorf("got %s after modifying mapped value, want %s", v, wantMapping["1990"])
+	}
+
+	// So are the keys and values.
+	keys, err = nineties.Keys()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	values, err = nineties.Values()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	keys[0][0], values[0][0] = 'x', 'x'
+	if v, _ := years.Get([]byte("1990")); !bytes.Equal(v, wantMapping["1990"]) {
+		t.Errorf("got %s after modifying keys and values, want %s", v, wantMapping["1990"])
+	}
+
 	if err = bx.Delete([]byte("years")); err != nil {
 		t.Error(err.Error())
 	}
 }
+
+// Ensure range scans handle open-ended and inverted bounds.
+func TestRangeScannerBounds(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, err := bx.New([]byte("years"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	yearItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1990"), []byte("90")},
+		{[]byte("1995"), []byte("95")},
+		{[]byte("2000"), []byte("00")},
+		{[]byte("2005"), []byte("05")},
+	}
+
+	if err = years.Insert(yearItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		min, max []byte
+		want     []string
+	}{
+		{nil, []byte("1995"), []string{"1990", "1995"}},
+		{[]byte("2000"), nil, []string{"2000", "2005"}},
+		{nil, nil, []string{"1990", "1995", "2000", "2005"}},
+		{[]byte("2000"), []byte("1990"), []string{}},
+	}
+
+	for _, tt := range tests {
+		items, err := years.NewRangeScanner(tt.min, tt.max).Items()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(items) != len(tt.want) {
+			t.Errorf("range %q-%q: got %d items, want %d",
+				tt.min, tt.max, len(items), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if got := items[i].Key; string(got) != want {
+				t.Errorf("range %q-%q: got %s, want %s", tt.min, tt.max, got, want)
+			}
+		}
+	}
+}
+
+// Ensure we can limit and offset range scans.
+func TestRangeScannerLimitOffset(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, err := bx.New([]byte("years"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	yearItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1985"), []byte("85")},
+		{[]byte("1990"), []byte("90")},
+		{[]byte("1995"), []byte("95")},
+		{[]byte("2000"), []byte("00")},
+		{[]byte("2005"), []byte("05")},
+	}
+
+	if err = years.Insert(yearItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	scanner := years.NewRangeScanner([]byte("1990"), []byte("2005"))
+
+	items, err := scanner.WithOffset(1).WithLimit(2).Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := []string{"1995", "2000"}
+	if len(items) != len(expected) {
+		t.Fatalf("got %d items, want %d", len(items), len(expected))
+	}
+	for i, want := range expected {
+		if got := items[i].Key; string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+// Ensure we can scan ranges in reverse order.
+func TestRangeScannerReverse(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, err := bx.New([]byte("years"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	yearItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1985"), []byte("85")},
+		{[]byte("1990"), []byte("90")},
+		{[]byte("1995"), []byte("95")},
+		{[]byte("2000"), []byte("00")},
+		{[]byte("2005"), []byte("05")},
+	}
+
+	if err = years.Insert(yearItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		min, max []byte
+		want     []string
+	}{
+		{[]byte("1990"), []byte("2000"), []string{"2000", "1995", "1990"}},
+		{[]byte("1991"), []byte("1999"), []string{"1995"}},
+		{nil, []byte("1990"), []string{"1990", "1985"}},
+		{[]byte("2000"), nil, []string{"2005", "2000"}},
+		{[]byte("2000"), []byte("1990"), []string{}},
+	}
+
+	for _, tt := range tests {
+		keys, err := years.NewRangeScanner(tt.min, tt.max).Reverse().Keys()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(keys) != len(tt.want) {
+			t.Errorf("range %q-%q: got %d keys, want %d",
+				tt.min, tt.max, len(keys), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if got := keys[i]; string(got) != want {
+				t.Errorf("range %q-%q: got %s, want %s", tt.min, tt.max, got, want)
+			}
+		}
+	}
+
+	items, err := years.NewRangeScanner([]byte("1995"), nil).ItemsReverse()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 3 || string(items[0].Value) != "05" || string(items[2].Value) != "95" {
+		t.Errorf("got %q, want items for 2005, 2000, 1995", items)
+	}
+}
+
+// Ensure range bounds can be excluded, in either scan direction.
+func TestRangeScannerExclusive(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, err := bx.New([]byte("years"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	yearItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1990"), []byte("90")},
+		{[]byte("1995"), []byte("95")},
+		{[]byte("2000"), []byte("00")},
+		{[]byte("2005"), []byte("05")},
+	}
+
+	if err = years.Insert(yearItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		min, max               []byte
+		excludeMin, excludeMax bool
+		want                   string
+	}{
+		{[]byte("1990"), []byte("2000"), true, false, "1995 2000"},
+		{[]byte("1990"), []byte("2000"), false, true, "1990 1995"},
+		{[]byte("1990"), []byte("2000"), true, true, "1995"},
+		{[]byte("1991"), []byte("2001"), true, true, "1995 2000"},
+		{[]byte("2005"), nil, true, false, ""},
+		{nil, []byte("1990"), false, true, ""},
+	}
+
+	for _, tt := range tests {
+		scanner := years.NewRangeScanner(tt.min, tt.max)
+		if tt.excludeMin {
+			scanner = scanner.ExcludeMin()
+		}
+		if tt.excludeMax {
+			scanner = scanner.ExcludeMax()
+		}
+		for _, reverse := range []bool{false, true} {
+			s := scanner
+			if reverse {
+				s = scanner.Reverse()
+			}
+			keys, err := s.Keys()
+			if err != nil {
+				t.Error(err.Error())
+			}
+			want := strings.Fields(tt.want)
+			if reverse {
+				for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+					want[i], want[j] = want[j], want[i]
+				}
+			}
+			if got := fmt.Sprintf("%s", keys); got != fmt.Sprintf("%s", want) {
+				t.Errorf("range %q-%q (exclude %v/%v, reverse %v): got %s, want %s",
+					tt.min, tt.max, tt.excludeMin, tt.excludeMax, reverse, got, want)
+			}
+		}
+	}
+}
+
+// Ensure we can page through range scans.
+func TestRangeScannerPage(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, err := bx.New([]byte("years"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	yearItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1990"), []byte("90")},
+		{[]byte("1995"), []byte("95")},
+		{[]byte("2000"), []byte("00")},
+	}
+
+	if err = years.Insert(yearItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	scanner := years.NewRangeScanner([]byte("1990"), []byte("2000"))
+
+	page, err := scanner.Page(0, 2)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(page) != 2 || string(page[0].Key) != "1990" || string(page[1].Key) != "1995" {
+		t.Errorf("got %v, want page with keys 1990 and 1995", page)
+	}
+
+	// The page is a copy, safe to modify.
+	page[0].Key[0], page[0].Value[0] = 'x', 'x'
+	if v, _ := years.Get([]byte("1990")); string(v) != "90" {
+		t.Errorf("got %q after modifying page, want %q", v, "90")
+	}
+
+	page, err = scanner.Page(3, 2)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if page == nil || len(page) != 0 {
+		t.Errorf("got %#v, want empty page", page)
+	}
+}
+
+// Ensure we can get the first and last items within a range.
+func TestRangeScannerFirstLast(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, err := bx.New([]byte("years"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	yearItems := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("1990"), []byte("90")},
+		{[]byte("1995"), []byte("95")},
+		{[]byte("2000"), []byte("00")},
+		{[]byte("2005"), []byte("05")},
+	}
+	if err = years.Insert(yearItems); err != nil {
+		t.Error(err.Error())
+	}
+
+	tests := []struct {
+		scanner     *buckets.RangeScanner
+		first, last string
+	}{
+		{years.NewRangeScanner([]byte("1991"), []byte("2004")), "1995", "2000"},
+		{years.NewRangeScanner(nil, nil).WithLimit(1), "1990", "2005"},
+		{years.NewRangeScanner([]byte("1990"), []byte("2005")).ExcludeMin().ExcludeMax(), "1995", "2000"},
+	}
+
+	for _, tt := range tests {
+		first, err := tt.scanner.First()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		last, err := tt.scanner.Last()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if string(first.Key) != tt.first || string(last.Key) != tt.last {
+			t.Errorf("got %s-%s, want %s-%s", first.Key, last.Key, tt.first, tt.last)
+		}
+	}
+
+	empty := years.NewRangeScanner([]byte("2001"), []byte("2004"))
+	if _, err := empty.First(); err != buckets.ErrEmpty {
+		t.Errorf("got %v, want ErrEmpty", err)
+	}
+	if _, err := empty.Last(); err != buckets.ErrEmpty {
+		t.Errorf("got %v, want ErrEmpty", err)
+	}
+}
@@ -0,0 +1,85 @@
+package buckets
+
+import "github.com/boltdb/bolt"
+
+// A ReadTx is a read-only transaction spanning several buckets.  Buckets
+// opened within a ReadTx (see ReadTx.Bucket) only have methods for
+// reading, so writes within a read path are caught at compile time, and
+// all their reads see the same consistent view of the database.
+//
+// Like a bolt transaction, a ReadTx must only be used by one goroutine,
+// and only while the func passed to View runs.
+type ReadTx struct {
+	tx *Tx
+}
+
+// View applies `fn` within a read-only transaction.  Note that this hides
+// the View method of the embedded bolt.DB; call db.DB.View for a bolt
+// read-only transaction.
+func (db *DB) View(fn func(tx *ReadTx) error) error {
+	return db.DB.View(func(t *bolt.Tx) error {
+		tx := &Tx{db: db, tx: t}
+		defer func() { tx.tx = nil }()
+		return fn(&ReadTx{tx})
+	})
+}
+
+// Bucket opens the named bucket within the ReadTx.  If the bucket doesn't
+// exist, Bucket returns ErrBucketNotFound.
+func (tx *ReadTx) Bucket(name []byte) (*ReadBucket, error) {
+	bk, err := tx.tx.Bucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadBucket{bk}, nil
+}
+
+// A ReadBucket is a bucket opened within a ReadTx.  Its methods work like
+// those of Bucket, reading as part of the ReadTx.
+type ReadBucket struct {
+	bk *Bucket
+}
+
+// Get retrieves the value for key `k`.
+func (rb *ReadBucket) Get(k []byte) ([]byte, error) {
+	return rb.bk.Get(k)
+}
+
+// Exists reports whether key `k` is in the bucket.
+func (rb *ReadBucket) Exists(k []byte) (bool, error) {
+	return rb.bk.Exists(k)
+}
+
+// Count returns a count of the keys in the bucket.
+func (rb *ReadBucket) Count() (int, error) {
+	return rb.bk.Count()
+}
+
+// Items returns a slice of key/value pairs.
+func (rb *ReadBucket) Items() ([]Item, error) {
+	return rb.bk.Items()
+}
+
+// PrefixItems returns a slice of key/value pairs for all keys with
+// prefix `pre`.
+func (rb *ReadBucket) PrefixItems(pre []byte) ([]Item, error) {
+	return rb.bk.PrefixItems(pre)
+}
+
+// RangeItems returns a slice of key/value pairs for all keys within the
+// range `min` to `max`, inclusive.
+func (rb *ReadBucket) RangeItems(min []byte, max []byte) ([]Item, error) {
+	return rb.bk.RangeItems(min, max)
+}
+
+// NewPrefixScanner initializes a new prefix scanner that reads within
+// the ReadTx.
+func (rb *ReadBucket) NewPrefixScanner(pre []byte) *PrefixScanner {
+	return rb.bk.NewPrefixScanner(pre)
+}
+
+// NewRangeScanner initializes a new range scanner that reads within the
+// ReadTx.
+func (rb *ReadBucket) NewRangeScanner(min, max []byte) *RangeScanner {
+	return rb.bk.NewRangeScanner(min, max)
+}
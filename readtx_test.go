@@ -0,0 +1,72 @@
+package buckets_test
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can read several buckets within a read-only transaction.
+func TestView(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	done, err := bx.New([]byte("done"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"a1", "a2", "b1"} {
+		if err := todos.Put([]byte(k), []byte("todo "+k)); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	if err := done.Put([]byte("c1"), []byte("done c1")); err != nil {
+		t.Error(err.Error())
+	}
+
+	var scanner *buckets.PrefixScanner
+	err = bx.View(func(tx *buckets.ReadTx) error {
+		if _, err := tx.Bucket([]byte("missing")); err != buckets.ErrBucketNotFound {
+			t.Errorf("got %v, want ErrBucketNotFound", err)
+		}
+		todos, err := tx.Bucket([]byte("todos"))
+		if err != nil {
+			return err
+		}
+		done, err := tx.Bucket([]byte("done"))
+		if err != nil {
+			return err
+		}
+		if v, err := todos.Get([]byte("a2")); err != nil || string(v) != "todo a2" {
+			t.Errorf("got %q, %v; want %q", v, err, "todo a2")
+		}
+		if ok, err := done.Exists([]byte("c1")); err != nil || !ok {
+			t.Errorf("got %v, %v; want c1 to exist", ok, err)
+		}
+		if n, err := todos.Count(); err != nil || n != 3 {
+			t.Errorf("got %d, %v; want 3 keys", n, err)
+		}
+		items, err := todos.RangeItems([]byte("a2"), []byte("b1"))
+		if err != nil || len(items) != 2 {
+			t.Errorf("got %d items, %v; want 2", len(items), err)
+		}
+		scanner = todos.NewPrefixScanner([]byte("a"))
+		if n, err := scanner.Count(); err != nil || n != 2 {
+			t.Errorf("got %d, %v; want 2 keys with prefix", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Buckets opened within a View can't be read once it returns.
+	if _, err := scanner.Count(); err != bolt.ErrTxClosed {
+		t.Errorf("got %v, want ErrTxClosed", err)
+	}
+}
@@ -0,0 +1,30 @@
+package buckets
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SchemaVersionKey is the reserved key under which a bucket's schema
+// version is stored.  Note that, like any other key, it's included when
+// getting or scanning the bucket's items.
+var SchemaVersionKey = []byte("_schema_version")
+
+// SetSchemaVersion stores `v` as the bucket's schema version.
+func (bk *Bucket) SetSchemaVersion(v int) error {
+	return bk.Put(SchemaVersionKey, []byte(strconv.Itoa(v)))
+}
+
+// GetSchemaVersion returns the bucket's schema version, or zero if no
+// version has been set.
+func (bk *Bucket) GetSchemaVersion() (int, error) {
+	value, err := bk.Get(SchemaVersionKey)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %s", value, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,46 @@
+package buckets_test
+
+import (
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can set and get a bucket's schema version.
+func TestSchemaVersion(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Unversioned buckets are at version zero.
+	v, err := things.GetSchemaVersion()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if v != 0 {
+		t.Errorf("got version %d, want %d", v, 0)
+	}
+
+	if err := things.SetSchemaVersion(3); err != nil {
+		t.Error(err.Error())
+	}
+	v, err = things.GetSchemaVersion()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if v != 3 {
+		t.Errorf("got version %d, want %d", v, 3)
+	}
+
+	// A corrupt version is reported as an error.
+	if err := things.Put(buckets.SchemaVersionKey, []byte("three")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := things.GetSchemaVersion(); err == nil {
+		t.Error("expected error for invalid schema version")
+	}
+}
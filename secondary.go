@@ -0,0 +1,232 @@
+package buckets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// A SecondaryIndex is a bucket mapping index keys, derived from the
+// items in a primary bucket, to the keys of those items.  The index is
+// updated within the same transaction as each put or delete made to the
+// primary bucket, so it's always consistent with the primary bucket.
+// Changes made via the primary bucket's Update method or by other
+// processes aren't indexed, however.
+type SecondaryIndex struct {
+	primary *Bucket
+	index   *Bucket
+	keyFn   func(k, v []byte) []byte
+}
+
+// NewSecondaryIndex creates a secondary index of bucket `primary` in the
+// bucket named `name`, indexing each item under the index key returned by
+// `keyFn` for the item's key and value.  Items for which `keyFn` returns
+// nil aren't indexed.  Several items can have the same index key.  If the
+// index bucket already exists, it's rebuilt from the items in `primary`.
+// The index is maintained until the database is closed.
+func (db *DB) NewSecondaryIndex(primary *Bucket, name []byte, keyFn func(k, v []byte) []byte) (*SecondaryIndex, error) {
+	idx := &SecondaryIndex{
+		primary: primary,
+		index:   &Bucket{db: db, Name: name},
+		keyFn:   keyFn,
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+		p := primary.bucket(tx)
+		if p == nil {
+			return ErrBucketNotFound
+		}
+		now := time.Now().UnixNano()
+		return p.ForEach(func(k, v []byte) error {
+			if v == nil || primary.expired(v, now) {
+				return nil
+			}
+			v, err := primary.decode(v)
+			if err != nil {
+				return err
+			}
+			return idx.update(tx, k, nil, keyFn(k, v))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	db.indexes = append(db.indexes, idx)
+	db.mu.Unlock()
+	return idx, nil
+}
+
+// Lookup returns the key of an item in the primary bucket with index key
+// `ik`, or nil if there's no such item.  If several items have the index
+// key, the first in byte-sorted order is returned.
+func (idx *SecondaryIndex) Lookup(ik []byte) (key []byte, err error) {
+	err = idx.scan(ik, func(k []byte) error {
+		key = k
+		return ErrStop
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LookupAll returns the keys, in byte-sorted order, of the items in the
+// primary bucket with index key `ik`.
+func (idx *SecondaryIndex) LookupAll(ik []byte) (keys [][]byte, err error) {
+	err = idx.scan(ik, func(k []byte) error {
+		keys = append(keys, k)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// scan applies `do` on a copy of each primary key with index key `ik`,
+// stopping without error if `do` returns ErrStop.
+func (idx *SecondaryIndex) scan(ik []byte, do func(k []byte) error) error {
+	pre := indexEntry(ik, nil)
+	err := idx.index.db.DB.View(func(tx *bolt.Tx) error {
+		b := idx.index.bucket(tx)
+		if b == nil {
+			return ErrBucketNotFound
+		}
+		c := b.Cursor()
+		for k, _ := c.Seek(pre); hasPrefix(k, pre); k, _ = c.Next() {
+			key := make([]byte, len(k)-len(pre))
+			copy(key, k[len(pre):])
+			if err := do(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrStop {
+		return nil
+	}
+	return err
+}
+
+// update moves primary key `k` from index key `old` to index key `new`
+// within transaction `tx`.  A nil index key means `k` isn't indexed.
+func (idx *SecondaryIndex) update(tx *bolt.Tx, k, old, new []byte) error {
+	b := idx.index.bucket(tx)
+	if b == nil {
+		return ErrBucketNotFound
+	}
+	if old != nil {
+		if err := b.Delete(indexEntry(old, k)); err != nil {
+			return err
+		}
+	}
+	if new != nil {
+		return b.Put(indexEntry(new, k), []byte{})
+	}
+	return nil
+}
+
+// indexEntry returns the key under which primary key `k` is stored in
+// an index bucket for index key `ik`: the length of `ik` as a uvarint,
+// followed by `ik` and `k`.  The length prefix keeps the entries for an
+// index key from being confused with those for longer index keys.
+func indexEntry(ik, k []byte) []byte {
+	entry := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(ik)+len(k))
+	n := binary.PutUvarint(entry, uint64(len(ik)))
+	return append(append(entry[:n], ik...), k...)
+}
+
+// indexesFor returns the secondary indexes whose primary bucket is `bk`.
+func (db *DB) indexesFor(bk *Bucket) (indexes []*SecondaryIndex) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, idx := range db.indexes {
+		if sameBucket(idx.primary, bk) {
+			indexes = append(indexes, idx)
+		}
+	}
+	return indexes
+}
+
+// path returns the names of the bucket's ancestors, outermost first,
+// followed by its own name.
+func (bk *Bucket) path() [][]byte {
+	var path [][]byte
+	for b := bk; b != nil; b = b.parent {
+		path = append([][]byte{b.Name}, path...)
+	}
+	return path
+}
+
+// samePath reports whether bucket paths `a` and `b` are the same.
+func samePath(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameBucket reports whether `a` and `b` refer to the same bucket.
+func sameBucket(a, b *Bucket) bool {
+	for a != nil && b != nil {
+		if !bytes.Equal(a.Name, b.Name) {
+			return false
+		}
+		a, b = a.parent, b.parent
+	}
+	return a == nil && b == nil
+}
+
+// indexKeys returns the index key, for each of the recorder's indexes,
+// of the current value of key `k`.  An expired value is still indexed
+// until it's removed, so its index keys are returned too.
+func (r *recorder) indexKeys(k []byte) [][]byte {
+	if len(r.indexes) == 0 {
+		return nil
+	}
+	keys := make([][]byte, len(r.indexes))
+	v := r.Bucket.Get(k)
+	if v == nil {
+		return keys
+	}
+	v, err := r.bk.decode(v)
+	if err != nil {
+		return keys
+	}
+	for i, idx := range r.indexes {
+		keys[i] = idx.keyFn(k, v)
+	}
+	return keys
+}
+
+// reindex updates the recorder's indexes for key `k`, given its index
+// keys before the change (as returned by indexKeys) and its new value
+// `v`, which is nil if the key was deleted.
+func (r *recorder) reindex(k []byte, old [][]byte, v []byte) error {
+	for i, idx := range r.indexes {
+		var ik []byte
+		if v != nil {
+			ik = idx.keyFn(k, v)
+		}
+		if (ik == nil) == (old[i] == nil) && bytes.Equal(ik, old[i]) {
+			continue
+		}
+		if err := idx.update(r.Tx(), k, old[i], ik); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package buckets_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure a secondary index tracks puts and deletes to its primary bucket.
+func TestSecondaryIndex(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	users, err := bx.New([]byte("users"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Values are "email city"; existing items are indexed on creation.
+	if err := users.Put([]byte("u1"), []byte("ann@example.com paris")); err != nil {
+		t.Error(err.Error())
+	}
+
+	field := func(n int) func(k, v []byte) []byte {
+		return func(k, v []byte) []byte {
+			fields := bytes.Fields(v)
+			if len(fields) <= n {
+				return nil
+			}
+			return fields[n]
+		}
+	}
+	byEmail, err := bx.NewSecondaryIndex(users, []byte("users_by_email"), field(0))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	byCity, err := bx.NewSecondaryIndex(users, []byte("users_by_city"), field(1))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("u2"), []byte("bob@example.com paris")},
+		{[]byte("u3"), []byte("cat@example.com rome")},
+		{[]byte("u4"), []byte("dan@example.com")}, // no city
+	}
+	if err := users.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	lookup := func(ik string) string {
+		k, err := byEmail.Lookup([]byte(ik))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		return string(k)
+	}
+	lookupAll := func(ik string) string {
+		keys, err := byCity.LookupAll([]byte(ik))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		return fmt.Sprintf("%s", keys)
+	}
+
+	if got := lookup("ann@example.com"); got != "u1" {
+		t.Errorf("got %q, want u1", got)
+	}
+	if got := lookup("cat@example.com"); got != "u3" {
+		t.Errorf("got %q, want u3", got)
+	}
+	if got := lookupAll("paris"); got != "[u1 u2]" {
+		t.Errorf("got %s, want [u1 u2]", got)
+	}
+
+	// Updating an item moves it to its new index key.
+	if err := users.Put([]byte("u2"), []byte("bob@example.org rome")); err != nil {
+		t.Error(err.Error())
+	}
+	if got := lookup("bob@example.com"); got != "" {
+		t.Errorf("got %q for stale index key, want none", got)
+	}
+	if got := lookup("bob@example.org"); got != "u2" {
+		t.Errorf("got %q, want u2", got)
+	}
+	if got := lookupAll("rome"); got != "[u2 u3]" {
+		t.Errorf("got %s, want [u2 u3]", got)
+	}
+
+	// Deleting an item removes it from the index.
+	if err := users.Delete([]byte("u1")); err != nil {
+		t.Error(err.Error())
+	}
+	if got := lookupAll("paris"); got != "[]" {
+		t.Errorf("got %s, want []", got)
+	}
+
+	// Looking up a deleted index is an error.
+	if err := bx.Delete([]byte("users_by_city")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := byCity.LookupAll([]byte("rome")); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+}
+
+// Ensure the index entries of expired keys are removed once the keys are
+// overwritten or swept.
+func TestSecondaryIndexTTL(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	sessions, err := bx.NewTTL([]byte("sessions"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	byUser, err := bx.NewSecondaryIndex(sessions, []byte("sessions_by_user"),
+		func(k, v []byte) []byte { return v })
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	lookupAll := func(ik string) string {
+		keys, err := byUser.LookupAll([]byte(ik))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		return fmt.Sprintf("%s", keys)
+	}
+
+	for _, k := range []string{"s1", "s2"} {
+		if err := sessions.PutWithTTL([]byte(k), []byte("ann"), -time.Second); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	// Overwriting an expired key moves it to its new index key.
+	if err := sessions.Put([]byte("s1"), []byte("bob")); err != nil {
+		t.Error(err.Error())
+	}
+	if got := lookupAll("bob"); got != "[s1]" {
+		t.Errorf("got %s, want [s1]", got)
+	}
+	if got := lookupAll("ann"); got != "[s2]" {
+		t.Errorf("got %s, want [s2]", got)
+	}
+
+	// Sweeping an expired key removes it from the index.
+	if err := bx.StartSweeper(time.Millisecond); err != nil {
+		t.Fatal(err.Error())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for lookupAll("ann") != "[]" {
+		if time.Now().After(deadline) {
+			t.Fatal("index entry of swept key not removed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	bx.StopSweeper()
+}
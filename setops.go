@@ -0,0 +1,128 @@
+package buckets
+
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+)
+
+// IntersectKeys returns the keys present in both this bucket and bucket
+// `other`, in byte-sorted order.  Both buckets are walked together in a
+// single read-only transaction, in time proportional to the sum of their
+// sizes, and no values are read.  Both buckets must belong to the same
+// database.
+func (bk *Bucket) IntersectKeys(other *Bucket) (keys [][]byte, err error) {
+	defer bk.observe("IntersectKeys", nil)(&err)
+	return bk.joinKeys(other, func(inBk, inOther bool) bool {
+		return inBk && inOther
+	})
+}
+
+// UnionKeys returns the keys present in this bucket, bucket `other`, or
+// both, in byte-sorted order and without duplicates.  Like IntersectKeys,
+// both buckets are walked together in a single read-only transaction.
+func (bk *Bucket) UnionKeys(other *Bucket) (keys [][]byte, err error) {
+	defer bk.observe("UnionKeys", nil)(&err)
+	return bk.joinKeys(other, func(inBk, inOther bool) bool {
+		return true
+	})
+}
+
+// DifferenceKeys returns the keys present in this bucket but not in
+// bucket `other`, in byte-sorted order.  Like IntersectKeys, both buckets
+// are walked together in a single read-only transaction.
+func (bk *Bucket) DifferenceKeys(other *Bucket) (keys [][]byte, err error) {
+	defer bk.observe("DifferenceKeys", nil)(&err)
+	return bk.joinKeys(other, func(inBk, inOther bool) bool {
+		return inBk && !inOther
+	})
+}
+
+// SymmetricDifferenceKeys returns the keys present in exactly one of this
+// bucket and bucket `other`, in byte-sorted order.  Like IntersectKeys,
+// both buckets are walked together in a single read-only transaction.
+func (bk *Bucket) SymmetricDifferenceKeys(other *Bucket) (keys [][]byte, err error) {
+	defer bk.observe("SymmetricDifferenceKeys", nil)(&err)
+	return bk.joinKeys(other, func(inBk, inOther bool) bool {
+		return inBk != inOther
+	})
+}
+
+// joinKeys walks the keys of this bucket and bucket `other` together, in
+// byte-sorted order, returning a copy of each key for which `keep`
+// returns true.  `keep` is passed whether the key is present in each
+// bucket.
+func (bk *Bucket) joinKeys(other *Bucket, keep func(inBk, inOther bool) bool) (keys [][]byte, err error) {
+	err = bk.view(func(tx *bolt.Tx) error {
+		if bk.bucket(tx) == nil || other.bucket(tx) == nil {
+			return ErrBucketNotFound
+		}
+		a, b := keyWalker{c: bk.cursor(tx)}, keyWalker{c: other.cursor(tx)}
+		ka, kb := a.first(), b.first()
+		for ka != nil || kb != nil {
+			var k []byte
+			var inA, inB bool
+			switch cmp := compareKeys(ka, kb); {
+			case cmp < 0:
+				k, inA = ka, true
+				ka = a.next()
+			case cmp > 0:
+				k, inB = kb, true
+				kb = b.next()
+			default:
+				k, inA, inB = ka, true, true
+				ka, kb = a.next(), b.next()
+			}
+			if keep(inA, inB) {
+				key := make([]byte, len(k))
+				copy(key, k)
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// A keyWalker steps a cursor through the keys of a bucket, skipping the
+// keys of nested buckets.
+type keyWalker struct {
+	c *cursor
+}
+
+// first returns the first key, or nil if there are none.
+func (w keyWalker) first() []byte {
+	k, v := w.c.First()
+	return w.skip(k, v)
+}
+
+// next returns the next key, or nil if there are no more.
+func (w keyWalker) next() []byte {
+	k, v := w.c.Next()
+	return w.skip(k, v)
+}
+
+// skip steps past nested buckets, starting at key `k` with value `v`.
+func (w keyWalker) skip(k, v []byte) []byte {
+	for k != nil && v == nil {
+		k, v = w.c.Next()
+	}
+	return k
+}
+
+// compareKeys compares keys `a` and `b` like bytes.Compare, but a nil key,
+// marking the end of a bucket, comes after every other key.
+func compareKeys(a, b []byte) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return bytes.Compare(a, b)
+}
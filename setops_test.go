@@ -0,0 +1,182 @@
+package buckets_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can get the keys present in both of two buckets.
+func TestIntersectKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	a, err := bx.New([]byte("a"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	b, err := bx.New([]byte("b"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"A", "B", "D", "F"} {
+		if err := a.Put([]byte(k), []byte("a")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	for _, k := range []string{"B", "C", "D", "G"} {
+		if err := b.Put([]byte(k), []byte("b")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	// Nested buckets aren't keys.
+	if _, err := a.NewChild([]byte("C")); err != nil {
+		t.Error(err.Error())
+	}
+
+	keys, err := a.IntersectKeys(b)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := strs(keys), []string{"B", "D"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	empty, err := bx.New([]byte("empty"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if keys, _ := a.IntersectKeys(empty); len(keys) != 0 {
+		t.Errorf("got %q, want no keys", keys)
+	}
+
+	// Either bucket having been deleted is an error.
+	if err := bx.Delete([]byte("empty")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := a.IntersectKeys(empty); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+	if _, err := empty.IntersectKeys(a); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+}
+
+// Ensure we can get the keys present in either of two buckets.
+func TestUnionKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	a, err := bx.New([]byte("a"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	b, err := bx.New([]byte("b"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"A", "B", "D"} {
+		if err := a.Put([]byte(k), []byte("a")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	for _, k := range []string{"B", "C", "D", "E"} {
+		if err := b.Put([]byte(k), []byte("b")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	keys, err := a.UnionKeys(b)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := strs(keys), []string{"A", "B", "C", "D", "E"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// Ensure we can get the keys present in one bucket but not another.
+func TestDifferenceKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	a, err := bx.New([]byte("a"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	b, err := bx.New([]byte("b"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"A", "B", "D", "F"} {
+		if err := a.Put([]byte(k), []byte("a")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	for _, k := range []string{"B", "C", "D"} {
+		if err := b.Put([]byte(k), []byte("b")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	keys, err := a.DifferenceKeys(b)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := strs(keys), []string{"A", "F"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	keys, err = b.DifferenceKeys(a)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := strs(keys), []string{"C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// Ensure we can get the keys present in exactly one of two buckets.
+func TestSymmetricDifferenceKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	a, err := bx.New([]byte("a"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	b, err := bx.New([]byte("b"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, k := range []string{"A", "B", "D", "F"} {
+		if err := a.Put([]byte(k), []byte("a")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	for _, k := range []string{"B", "C", "D", "G"} {
+		if err := b.Put([]byte(k), []byte("b")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	want := []string{"A", "C", "F", "G"}
+	for _, tt := range []struct{ bk, other *buckets.Bucket }{{a, b}, {b, a}} {
+		keys, err := tt.bk.SymmetricDifferenceKeys(tt.other)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got := strs(keys); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// strs returns `keys` as strings.
+func strs(keys [][]byte) []string {
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		s[i] = string(k)
+	}
+	return s
+}
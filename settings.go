@@ -0,0 +1,127 @@
+package buckets
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// settingsBucket is the name of the bucket nested within a bucket to
+// hold its persistent settings, e.g., whether its keys can expire (see
+// NewTTL).  Plain buckets have no settings bucket.  It's not listed by
+// SubBuckets.
+var settingsBucket = []byte("\x00bksettings")
+
+// ttlSetting is the settings key present in buckets opened with NewTTL.
+var ttlSetting = []byte("ttl")
+
+// loadSettings sets the bucket's persistent settings from bolt bucket
+// `b`, which holds the bucket's items.
+func (bk *Bucket) loadSettings(b *bolt.Bucket) {
+	bk.ttl = hasSetting(b, ttlSetting)
+	bk.tagged = hasSetting(b, taggedSetting)
+	bk.compressed = hasSetting(b, compressedSetting)
+}
+
+// saveSettings stores the bucket's persistent settings in bolt bucket
+// `b`, which holds the bucket's items.
+func (bk *Bucket) saveSettings(b *bolt.Bucket) error {
+	if bk.ttl {
+		if err := setSetting(b, ttlSetting); err != nil {
+			return err
+		}
+	}
+	if bk.tagged {
+		if err := setSetting(b, taggedSetting); err != nil {
+			return err
+		}
+	}
+	if bk.compressed {
+		return setSetting(b, compressedSetting)
+	}
+	return nil
+}
+
+// checkSettings returns an error if the bucket's persistent settings
+// differ from those stored in bolt bucket `b`, e.g., if the bucket was
+// opened before being reopened with NewTTL, NewTagged, or NewCompressed.
+// Writing through such a bucket would store values that other buckets
+// can't read.
+func (bk *Bucket) checkSettings(b *bolt.Bucket) error {
+	var stored Bucket
+	stored.loadSettings(b)
+	if !bk.sameSettings(&stored) {
+		return fmt.Errorf("bucket %s was reopened with different settings", bk.Name)
+	}
+	return nil
+}
+
+// sameSettings reports whether the bucket and bucket `other` have the
+// same persistent settings.
+func (bk *Bucket) sameSettings(other *Bucket) bool {
+	return bk.ttl == other.ttl && bk.tagged == other.tagged &&
+		bk.compressed == other.compressed
+}
+
+// hasSetting reports whether bolt bucket `b` has setting `key`.
+func hasSetting(b *bolt.Bucket, key []byte) bool {
+	settings := b.Bucket(settingsBucket)
+	return settings != nil && settings.Get(key) != nil
+}
+
+// setSetting stores setting `key` in bolt bucket `b`.
+func setSetting(b *bolt.Bucket, key []byte) error {
+	settings, err := b.CreateBucketIfNotExists(settingsBucket)
+	if err != nil {
+		return err
+	}
+	return settings.Put(key, []byte{1})
+}
+
+// copySettings replaces the settings of bolt bucket `dst` with those of
+// bolt bucket `src`.
+func copySettings(dst, src *bolt.Bucket) error {
+	if dst.Bucket(settingsBucket) != nil {
+		if err := dst.DeleteBucket(settingsBucket); err != nil {
+			return err
+		}
+	}
+	settings := src.Bucket(settingsBucket)
+	if settings == nil {
+		return nil
+	}
+	copied, err := dst.CreateBucket(settingsBucket)
+	if err != nil {
+		return err
+	}
+	return copyAll(copied, settings)
+}
+
+// newWithSetting creates/opens a named bucket with setting `key`.  If
+// the bucket already holds keys stored without the setting, it returns
+// an error rather than misreading them, unless `keepKeys` is set because
+// such keys are read correctly with the setting.
+func (db *DB) newWithSetting(name, key []byte, keepKeys bool) (*Bucket, error) {
+	bk := &Bucket{db: db, Name: name}
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		if !hasSetting(b, key) {
+			bk.loadSettings(b)
+			if !keepKeys && bk.count(tx) > 0 {
+				return fmt.Errorf("bucket %s already has keys stored without %s setting", name, key)
+			}
+			if err := setSetting(b, key); err != nil {
+				return err
+			}
+		}
+		bk.loadSettings(b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bk, nil
+}
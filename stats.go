@@ -0,0 +1,73 @@
+package buckets
+
+import "github.com/boltdb/bolt"
+
+// BucketStats describes the keys and pages of a bucket, including any
+// nested buckets.  It's a summary of bolt's BucketStats.
+type BucketStats struct {
+	KeyCount         int64 // number of keys
+	BucketCount      int64 // number of buckets, including this one
+	Depth            int64 // number of levels in the B+tree
+	BranchPageCount  int64 // number of branch pages
+	LeafPageCount    int64 // number of leaf pages
+	InlinedLeafCount int64 // number of buckets inlined in their parent's page
+	BranchInuse      int64 // bytes used by branch pages
+	LeafInuse        int64 // bytes used by leaf pages
+	BytesUsed        int64 // estimated bytes allocated to pages
+}
+
+// newBucketStats summarizes bolt's stats for a bucket, in a database with
+// pages of `pageSize` bytes.
+func newBucketStats(s bolt.BucketStats, pageSize int) BucketStats {
+	pages := s.BranchPageN + s.BranchOverflowN + s.LeafPageN + s.LeafOverflowN
+	return BucketStats{
+		KeyCount:         int64(s.KeyN),
+		BucketCount:      int64(s.BucketN),
+		Depth:            int64(s.Depth),
+		BranchPageCount:  int64(s.BranchPageN),
+		LeafPageCount:    int64(s.LeafPageN),
+		InlinedLeafCount: int64(s.InlineBucketN),
+		BranchInuse:      int64(s.BranchInuse),
+		LeafInuse:        int64(s.LeafInuse),
+		BytesUsed:        int64(pages) * int64(pageSize),
+	}
+}
+
+// Stats returns stats for each bucket in the database, keyed by bucket
+// name.  Nested buckets are included in the stats of their top-level
+// bucket.  Note that this hides the Stats method of the embedded bolt.DB,
+// which reports on the database's transactions and free pages; call
+// db.DB.Stats for those.
+func (db *DB) Stats() (map[string]BucketStats, error) {
+	stats := make(map[string]BucketStats)
+	err := db.DB.View(func(tx *bolt.Tx) error {
+		pageSize := tx.DB().Info().PageSize
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			stats[string(name)] = newBucketStats(b.Stats(), pageSize)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Stats returns stats for the bucket, including any buckets nested
+// within it.  The stats are a copy, safe to use after the read-only
+// transaction in which they're gathered.
+func (bk *Bucket) Stats() (stats BucketStats, err error) {
+	defer bk.observe("Stats", nil)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		b := bk.bucket(tx)
+		if b == nil {
+			return ErrBucketNotFound
+		}
+		stats = newBucketStats(b.Stats(), tx.DB().Info().PageSize)
+		return nil
+	})
+	if err != nil {
+		return BucketStats{}, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package buckets_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can get stats for each bucket in the db.
+func TestDBStats(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	for i := 0; i < 100; i++ {
+		k := []byte(fmt.Sprintf("key%03d", i))
+		if err := things.Put(k, []byte("value")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	if _, err := bx.New([]byte("empty")); err != nil {
+		t.Error(err.Error())
+	}
+
+	stats, err := bx.Stats()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(stats) != 2 {
+		t.Errorf("got stats for %d buckets, want 2", len(stats))
+	}
+	if got := stats["things"].KeyCount; got != 100 {
+		t.Errorf("got %d keys, want 100", got)
+	}
+	if got := stats["things"].LeafPageCount; got < 1 {
+		t.Errorf("got %d leaf pages, want at least 1", got)
+	}
+	empty := stats["empty"]
+	if empty.KeyCount != 0 || empty.InlinedLeafCount != 1 {
+		t.Errorf("got %+v, want empty inlined bucket", empty)
+	}
+}
+
+// Ensure we can get stats for a single bucket.
+func TestBucketStats(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	for i := 0; i < 1000; i++ {
+		k := []byte(fmt.Sprintf("key%04d", i))
+		if err := things.Put(k, []byte("value")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	stats, err := things.Stats()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if stats.KeyCount != 1000 {
+		t.Errorf("got %d keys, want 1000", stats.KeyCount)
+	}
+	if stats.BranchPageCount < 1 || stats.LeafPageCount < 2 {
+		t.Errorf("got %d branch and %d leaf pages, want a multi-level tree",
+			stats.BranchPageCount, stats.LeafPageCount)
+	}
+	pages := stats.BranchPageCount + stats.LeafPageCount
+	if stats.BytesUsed < pages*int64(bx.Info().PageSize) {
+		t.Errorf("got %d bytes used for %d pages", stats.BytesUsed, pages)
+	}
+	if stats.BytesUsed < stats.BranchInuse+stats.LeafInuse {
+		t.Errorf("got %d bytes used, want at least %d in use",
+			stats.BytesUsed, stats.BranchInuse+stats.LeafInuse)
+	}
+
+	if err := bx.Delete([]byte("things")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := things.Stats(); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v for deleted bucket, want ErrBucketNotFound", err)
+	}
+}
@@ -0,0 +1,203 @@
+package buckets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/boltdb/bolt"
+)
+
+// taggedMagic is the header prepended to tagged values (see TaggedPut).
+var taggedMagic = []byte("\x00bkt")
+
+// errBadTags is returned when a tagged value's tags can't be parsed.
+var errBadTags = errors.New("malformed value tags")
+
+// taggedSetting is the settings key present in buckets opened with
+// NewTagged.
+var taggedSetting = []byte("tagged")
+
+// NewTagged creates/opens a named bucket whose values can be tagged (see
+// TaggedPut).  Each value in the bucket is stored with a header holding
+// its tags, which Get and the other read methods strip, so the value is
+// read back as is.  Values of other buckets are stored as is, so they
+// can't be tagged.
+//
+// Like NewTTL, whether a bucket's values can be tagged is stored in the
+// database, and an existing bucket can only be made a tagged bucket
+// while it's empty.
+func (db *DB) NewTagged(name []byte) (*Bucket, error) {
+	return db.newWithSetting(name, taggedSetting, false)
+}
+
+// TaggedPut inserts value `v` with key `k`, tagging it with `tags`.
+// Putting the key again without TaggedPut drops its tags.  Only the
+// values of buckets opened with NewTagged can be tagged; for other
+// buckets, TaggedPut returns an error.
+func (bk *Bucket) TaggedPut(k, v []byte, tags map[string]string) (err error) {
+	defer bk.observe("TaggedPut", k)(&err)
+	if !bk.tagged {
+		return fmt.Errorf("bucket %s can't hold tagged values; open it with NewTagged", bk.Name)
+	}
+	header := encodeTags(tags)
+	return bk.update(func(b *recorder) error {
+		return b.store(k, v, header, never)
+	})
+}
+
+// Tags retrieves the tags for key `k`, without copying its value.  A key
+// put without tags, or in a bucket not opened with NewTagged, has no
+// tags.  If the key doesn't exist, Tags returns ErrKeyNotFound.
+func (bk *Bucket) Tags(k []byte) (tags map[string]string, err error) {
+	defer bk.observe("Tags", k)(&err)
+	err = bk.view(func(tx *bolt.Tx) error {
+		v := bk.get(tx, k)
+		if v == nil {
+			return ErrKeyNotFound
+		}
+		if !bk.tagged {
+			return nil
+		}
+		v, err := bk.unpack(v)
+		if err != nil {
+			return err
+		}
+		header, _, err := splitTags(v)
+		if err != nil {
+			return err
+		}
+		tags, err = parseTags(header)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// FilterByTag returns a slice of the key/value pairs whose tags map
+// `name` to `value`.
+func (bk *Bucket) FilterByTag(name, value string) (items []Item, err error) {
+	defer bk.observe("FilterByTag", nil)(&err)
+	if !bk.tagged {
+		return nil, nil
+	}
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			v, err := bk.unpack(v)
+			if err != nil {
+				return err
+			}
+			header, v, err := splitTags(v)
+			if err != nil {
+				return err
+			}
+			if len(header) == 0 {
+				continue
+			}
+			tags, err := parseTags(header)
+			if err != nil {
+				return err
+			}
+			if t, ok := tags[name]; !ok || t != value {
+				continue
+			}
+			item := Item{make([]byte, len(k)), make([]byte, len(v))}
+			copy(item.Key, k)
+			copy(item.Value, v)
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// encodeTags returns `tags` encoded as each tag name and value, in name
+// order, as length-prefixed strings.
+func encodeTags(tags map[string]string) []byte {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var header []byte
+	for _, name := range names {
+		header = appendString(header, name)
+		header = appendString(header, tags[name])
+	}
+	return header
+}
+
+// withTags returns value `v` prefixed with a header holding tags encoded
+// by encodeTags: the tagged magic, the length of the encoded tags as a
+// uvarint, and then the encoded tags.
+func withTags(v, header []byte) []byte {
+	var n [binary.MaxVarintLen64]byte
+	value := append([]byte{}, taggedMagic...)
+	value = append(value, n[:binary.PutUvarint(n[:], uint64(len(header)))]...)
+	value = append(value, header...)
+	return append(value, v...)
+}
+
+// appendString appends `s` to `b`, prefixed with its length as a uvarint.
+func appendString(b []byte, s string) []byte {
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(s)))]...)
+	return append(b, s...)
+}
+
+// splitTags splits value `v` into its encoded tags and the original
+// value.  If `v` isn't tagged, the returned tags are nil.
+func splitTags(v []byte) (header, value []byte, err error) {
+	if !bytes.HasPrefix(v, taggedMagic) {
+		return nil, v, nil
+	}
+	v = v[len(taggedMagic):]
+	size, n := binary.Uvarint(v)
+	if n <= 0 || size > uint64(len(v)-n) {
+		return nil, nil, errBadTags
+	}
+	v = v[n:]
+	return v[:size:size], v[size:], nil
+}
+
+// parseTags parses tags encoded by encodeTags.
+func parseTags(header []byte) (map[string]string, error) {
+	if len(header) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string)
+	for len(header) > 0 {
+		var name, value string
+		var ok bool
+		if name, header, ok = readString(header); !ok {
+			return nil, errBadTags
+		}
+		if value, header, ok = readString(header); !ok {
+			return nil, errBadTags
+		}
+		tags[name] = value
+	}
+	return tags, nil
+}
+
+// readString reads a length-prefixed string from the front of `b`,
+// returning the rest of `b`.
+func readString(b []byte) (s string, rest []byte, ok bool) {
+	size, n := binary.Uvarint(b)
+	if n <= 0 || size > uint64(len(b)-n) {
+		return "", nil, false
+	}
+	b = b[n:]
+	return string(b[:size]), b[size:], true
+}
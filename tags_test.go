@@ -0,0 +1,158 @@
+package buckets_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can tag values and filter by tag.
+func TestTaggedPut(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	docs, err := bx.NewTagged([]byte("docs"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	draft := map[string]string{"status": "draft", "owner": "ann"}
+	if err := docs.TaggedPut([]byte("a"), []byte("alpha"), draft); err != nil {
+		t.Error(err.Error())
+	}
+	final := map[string]string{"status": "final"}
+	if err := docs.TaggedPut([]byte("b"), []byte("bravo"), final); err != nil {
+		t.Error(err.Error())
+	}
+	if err := docs.TaggedPut([]byte("c"), []byte("charlie"), draft); err != nil {
+		t.Error(err.Error())
+	}
+	if err := docs.Put([]byte("d"), []byte("delta")); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Values are read back without their tags.
+	if v, _ := docs.Get([]byte("a")); string(v) != "alpha" {
+		t.Errorf("got %q, want alpha", v)
+	}
+	values, err := docs.NewPrefixScanner(nil).Values()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := []string{"alpha", "bravo", "charlie", "delta"}
+	for i, v := range values {
+		if string(v) != want[i] {
+			t.Errorf("got %q, want %q", v, want[i])
+		}
+	}
+
+	tags, err := docs.Tags([]byte("a"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if !reflect.DeepEqual(tags, draft) {
+		t.Errorf("got tags %v, want %v", tags, draft)
+	}
+	if tags, _ := docs.Tags([]byte("d")); len(tags) != 0 {
+		t.Errorf("got tags %v for untagged value, want none", tags)
+	}
+	if _, err := docs.Tags([]byte("z")); err != buckets.ErrKeyNotFound {
+		t.Errorf("got %v, want ErrKeyNotFound", err)
+	}
+
+	items, err := docs.FilterByTag("status", "draft")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 2 || string(items[0].Key) != "a" || string(items[1].Value) != "charlie" {
+		t.Errorf("got %q, want drafts a and c", items)
+	}
+	if items, _ := docs.FilterByTag("status", "missing"); len(items) != 0 {
+		t.Errorf("got %q, want no items", items)
+	}
+
+	// Putting a value again without tags drops them.
+	if err := docs.Put([]byte("a"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if tags, _ := docs.Tags([]byte("a")); len(tags) != 0 {
+		t.Errorf("got tags %v after untagged put, want none", tags)
+	}
+}
+
+// Ensure tags work with compressed buckets.
+func TestTaggedPutCompressed(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	if _, err := bx.NewTagged([]byte("docs")); err != nil {
+		t.Error(err.Error())
+	}
+	docs, err := bx.NewCompressed([]byte("docs"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	tags := map[string]string{"lang": "en"}
+	if err := docs.TaggedPut([]byte("a"), []byte("alpha"), tags); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := docs.Get([]byte("a")); string(v) != "alpha" {
+		t.Errorf("got %q, want alpha", v)
+	}
+	if got, _ := docs.Tags([]byte("a")); !reflect.DeepEqual(got, tags) {
+		t.Errorf("got tags %v, want %v", got, tags)
+	}
+}
+
+// Ensure values of buckets that can't be tagged are stored as is, even
+// if they look like tagged values.
+func TestUntaggedBucket(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	values := []string{"\x00bkt\x02\x01a-rest", "\x00bkt\xff", "\x00bkt"}
+	for i, v := range values {
+		k := []byte{byte('a' + i)}
+		if err := things.Put(k, []byte(v)); err != nil {
+			t.Error(err.Error())
+		}
+		got, err := things.Get(k)
+		if err != nil || string(got) != v {
+			t.Errorf("got %q, %v; want %q", got, err, v)
+		}
+		inserted, err := things.PutIfAbsent(k, []byte("other"))
+		if err != nil || inserted {
+			t.Errorf("got inserted %v, %v; want existing %q kept", inserted, err, v)
+		}
+		if tags, err := things.Tags(k); err != nil || tags != nil {
+			t.Errorf("got tags %v, %v; want none", tags, err)
+		}
+	}
+	err = things.TaggedPut([]byte("z"), []byte("zulu"), map[string]string{"a": "b"})
+	if err == nil {
+		t.Error("got no error tagging value of untagged bucket")
+	}
+
+	// Values put without tags in a tagged bucket read back as is, too.
+	docs, err := bx.NewTagged([]byte("docs"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for i, v := range values {
+		k := []byte{byte('a' + i)}
+		if err := docs.Put(k, []byte(v)); err != nil {
+			t.Error(err.Error())
+		}
+		if got, err := docs.Get(k); err != nil || string(got) != v {
+			t.Errorf("got %q, %v; want %q", got, err, v)
+		}
+	}
+	if _, err := bx.NewTagged([]byte("things")); err == nil {
+		t.Error("got no error making non-empty bucket a tagged bucket")
+	}
+}
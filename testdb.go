@@ -0,0 +1,44 @@
+package buckets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// OpenTestDB opens a buckets database in a temporary directory for use
+// in tests.  The database is closed when the test completes, and the
+// directory is then removed by the test runner.
+func OpenTestDB(t testing.TB) (*DB, error) {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db, nil
+}
+
+// OpenTemp opens a buckets database in a new temporary file.  The file
+// is removed when the database is closed.
+func OpenTemp() (*DB, error) {
+	f, err := ioutil.TempFile("", "buckets-")
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	db, err := Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	db.temp = true
+	return db, nil
+}
@@ -0,0 +1,72 @@
+package buckets_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure a test db is usable and cleaned up when the test completes.
+func TestOpenTestDB(t *testing.T) {
+	var path string
+
+	t.Run("open", func(t *testing.T) {
+		bx, err := buckets.OpenTestDB(t)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		path = bx.Path()
+
+		things, err := bx.New([]byte("things"))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		k, v := []byte("A"), []byte("alpha")
+		if err := things.Put(k, v); err != nil {
+			t.Error(err.Error())
+		}
+		if got, _ := things.Get(k); !bytes.Equal(got, v) {
+			t.Errorf("got %q, want %q", got, v)
+		}
+	})
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("test db %s not removed after test", path)
+	}
+}
+
+// Ensure a temp db's file is removed on close, unlike a regular db's.
+func TestOpenTemp(t *testing.T) {
+	bx, err := buckets.OpenTemp()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	path := bx.Path()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("temp db file missing: %v", err)
+	}
+	if _, err := bx.New([]byte("things")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := bx.Close(); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("temp db %s not removed on close", path)
+	}
+
+	path = tempfile()
+	defer os.Remove(path)
+	bx, err = buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := bx.Close(); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("regular db file removed on close: %v", err)
+	}
+}
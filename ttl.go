@@ -0,0 +1,226 @@
+package buckets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// expirySize is the length of the expiry timestamp that PutWithTTL
+// prepends to values.
+const expirySize = 8
+
+// never is the expiry timestamp of values that don't expire.
+const never = math.MaxInt64
+
+// NewTTL creates/opens a named bucket whose keys can expire.  Each value
+// is stored with an expiry time, set by PutWithTTL (values put otherwise
+// never expire).  Expired keys are treated as missing by the bucket's
+// read methods, even before they're removed.  They're removed by the
+// database's sweeper (see StartSweeper), or by EnableTTL.
+//
+// Whether a bucket's keys can expire is stored in the database, so the
+// bucket works the same way when opened with New or Bucket, including
+// after the database is reopened.  An existing bucket can only be made a
+// TTL bucket while it's empty, since its values weren't stored with
+// expiry times.
+func (db *DB) NewTTL(name []byte) (*Bucket, error) {
+	return db.newWithSetting(name, ttlSetting, false)
+}
+
+// PutWithTTL inserts value `v` with key `k`, to expire `ttl` from now.
+// For a bucket opened with NewTTL, the value is read back as is, and
+// the key is treated as missing once it expires.
+//
+// For other buckets, the value is prefixed with its expiry time, as an
+// 8-byte big-endian count of nanoseconds since the Unix epoch.  Once
+// expired, the key is removed by the bucket's TTL goroutine (see
+// EnableTTL).  Note that, for such buckets, Get and the other read
+// methods return the value with its expiry prefix.
+func (bk *Bucket) PutWithTTL(k, v []byte, ttl time.Duration) (err error) {
+	defer bk.observe("PutWithTTL", k)(&err)
+	expiry := time.Now().Add(ttl).UnixNano()
+	return bk.update(func(b *recorder) error {
+		if bk.ttl {
+			return b.put(k, v, expiry)
+		}
+		return b.Put(k, withExpiry(v, expiry))
+	})
+}
+
+// withExpiry returns value `v` prefixed with expiry time `expiry`.
+func withExpiry(v []byte, expiry int64) []byte {
+	value := make([]byte, expirySize+len(v))
+	binary.BigEndian.PutUint64(value, uint64(expiry))
+	copy(value[expirySize:], v)
+	return value
+}
+
+// expired reports whether stored value `v` expired before `now`, in
+// nanoseconds since the Unix epoch.  Only values in buckets opened with
+// NewTTL expire.
+func (bk *Bucket) expired(v []byte, now int64) bool {
+	return bk.ttl && len(v) >= expirySize && int64(binary.BigEndian.Uint64(v)) < now
+}
+
+// expiry returns the expiry time of stored value `v`, or never if the
+// bucket's keys don't expire.
+func (bk *Bucket) expiry(v []byte) int64 {
+	if !bk.ttl || len(v) < expirySize {
+		return never
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+// get returns the stored value for key `k` within transaction `tx`, or
+// nil if the key doesn't exist or has expired.
+func (bk *Bucket) get(tx *bolt.Tx, k []byte) []byte {
+	v := bk.bucket(tx).Get(k)
+	if bk.expired(v, time.Now().UnixNano()) {
+		return nil
+	}
+	return v
+}
+
+// EnableTTL starts a goroutine that checks the bucket for expired keys
+// every `interval` and removes them.  Unless the bucket was opened with
+// NewTTL, each value in the bucket is taken to begin with an expiry time,
+// as stored by PutWithTTL, so only enable TTL for buckets whose values
+// are all put that way.  Values too short to hold an expiry time are left
+// alone.  Expiry is best-effort: keys remain readable until the next check
+// after they expire.  The goroutine runs until the database is closed.
+func (bk *Bucket) EnableTTL(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid TTL check interval: %s", interval)
+	}
+	return bk.db.goroutine(func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				bk.expire(time.Now())
+			}
+		}
+	})
+}
+
+// expire removes keys whose values expired before `now`, returning the
+// number of keys removed.
+func (bk *Bucket) expire(now time.Time) (count int, err error) {
+	err = bk.update(func(b *recorder) error {
+		if b.Bucket == nil {
+			return nil
+		}
+		// Collect the expired keys before deleting any of them, since
+		// deleting under a bolt cursor can cause it to skip keys.
+		var keys [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue
+			}
+			expired := bk.expired(v, now.UnixNano())
+			if !bk.ttl {
+				// The expiry time is part of the value.
+				v, err := bk.decode(v)
+				expired = err == nil && len(v) >= expirySize &&
+					int64(binary.BigEndian.Uint64(v)) < now.UnixNano()
+			}
+			if expired {
+				key := make([]byte, len(k))
+				copy(key, k)
+				keys = append(keys, key)
+			}
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		count = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// A sweeper is a goroutine started by StartSweeper.
+type sweeper struct {
+	quit chan struct{} // closed to stop the goroutine
+	done chan struct{} // closed once the goroutine stops
+}
+
+// StartSweeper starts a goroutine that removes expired keys from the
+// database's TTL buckets (see NewTTL) every `interval`, each bucket in
+// its own transaction.  TTL buckets are found by their stored setting,
+// so they're swept whether or not they've been opened.  Any sweeper already started is stopped first.  The
+// sweeper runs until StopSweeper is called or the database is closed.
+func (db *DB) StartSweeper(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid sweep interval: %s", interval)
+	}
+	db.StopSweeper()
+	s := &sweeper{make(chan struct{}), make(chan struct{})}
+	err := db.goroutine(func(stop <-chan struct{}) {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-s.quit:
+				return
+			case <-ticker.C:
+				db.sweep(time.Now())
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	db.mu.Lock()
+	db.sweeper = s
+	db.mu.Unlock()
+	return nil
+}
+
+// StopSweeper stops the goroutine started by StartSweeper, waiting for
+// any sweep in progress to finish.
+func (db *DB) StopSweeper() {
+	db.mu.Lock()
+	s := db.sweeper
+	db.sweeper = nil
+	db.mu.Unlock()
+	if s != nil {
+		close(s.quit)
+		<-s.done
+	}
+}
+
+// sweep removes keys that expired before `now` from the database's TTL
+// buckets.
+func (db *DB) sweep(now time.Time) {
+	var ttls []*Bucket
+	db.DB.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if hasSetting(b, ttlSetting) {
+				bk := &Bucket{db: db, Name: append([]byte(nil), name...)}
+				bk.loadSettings(b)
+				ttls = append(ttls, bk)
+			}
+			return nil
+		})
+	})
+	for _, bk := range ttls {
+		bk.expire(now)
+	}
+}
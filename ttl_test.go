@@ -0,0 +1,387 @@
+package buckets_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure expired keys are removed by the TTL goroutine.
+func TestEnableTTL(t *testing.T) {
+	bx, err := buckets.OpenTemp()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+
+	sessions, err := bx.New([]byte("sessions"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if err := sessions.PutWithTTL([]byte("old"), []byte("a"), 10*time.Millisecond); err != nil {
+		t.Error(err.Error())
+	}
+	if err := sessions.PutWithTTL([]byte("new"), []byte("b"), time.Hour); err != nil {
+		t.Error(err.Error())
+	}
+
+	v, err := sessions.Get([]byte("new"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(v) != 9 || !bytes.Equal(v[8:], []byte("b")) {
+		t.Errorf("got %q, want value with 8-byte expiry prefix", v)
+	}
+
+	if err := sessions.EnableTTL(0); err == nil {
+		t.Error("expected error for zero interval")
+	}
+	if err := sessions.EnableTTL(5 * time.Millisecond); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ok, err := sessions.Has([]byte("old"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired key not removed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if ok, _ := sessions.Has([]byte("new")); !ok {
+		t.Error("unexpired key removed")
+	}
+}
+
+// Ensure closing a db stops its TTL goroutines.
+func TestEnableTTLClose(t *testing.T) {
+	bx, err := buckets.OpenTemp()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sessions, err := bx.New([]byte("sessions"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := sessions.EnableTTL(time.Millisecond); err != nil {
+		t.Fatal(err.Error())
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if err := bx.Close(); err != nil {
+		t.Error(err.Error())
+	}
+	if err := sessions.EnableTTL(time.Millisecond); err == nil {
+		t.Error("expected error enabling TTL on a closed db")
+	}
+}
+
+// Ensure expired keys in a TTL bucket are hidden from reads.
+func TestNewTTL(t *testing.T) {
+	bx, err := buckets.OpenTemp()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+
+	sessions, err := bx.NewTTL([]byte("sessions"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := sessions.PutWithTTL([]byte("a"), []byte("1"), -time.Second); err != nil {
+		t.Error(err.Error())
+	}
+	if err := sessions.PutWithTTL([]byte("b"), []byte("2"), time.Hour); err != nil {
+		t.Error(err.Error())
+	}
+	if err := sessions.Put([]byte("c"), []byte("3")); err != nil {
+		t.Error(err.Error())
+	}
+
+	v, err := sessions.Get([]byte("a"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if v != nil {
+		t.Errorf("got %q for expired key, want nil", v)
+	}
+	if ok, _ := sessions.Has([]byte("a")); ok {
+		t.Error("expired key reported as present")
+	}
+
+	for k, want := range map[string]string{"b": "2", "c": "3"} {
+		v, err := sessions.Get([]byte(k))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if !bytes.Equal(v, []byte(want)) {
+			t.Errorf("got %q for key %q, want %q", v, k, want)
+		}
+	}
+
+	items, err := sessions.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 2 || string(items[0].Key) != "b" || string(items[1].Key) != "c" {
+		t.Errorf("got %d items, want unexpired b and c", len(items))
+	}
+
+	n, err := sessions.NewPrefixScanner(nil).Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if n != 2 {
+		t.Errorf("prefix scan counted %d keys, want 2", n)
+	}
+	first, err := sessions.NewRangeScanner([]byte("a"), []byte("c")).First()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if string(first.Key) != "b" {
+		t.Errorf("range scan started at %q, want b", first.Key)
+	}
+}
+
+// Ensure the sweeper removes expired keys from TTL buckets.
+func TestStartSweeper(t *testing.T) {
+	bx, err := buckets.OpenTemp()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+
+	sessions, err := bx.NewTTL([]byte("sessions"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := sessions.PutWithTTL([]byte("old"), []byte("a"), 10*time.Millisecond); err != nil {
+		t.Error(err.Error())
+	}
+	if err := sessions.PutWithTTL([]byte("new"), []byte("b"), time.Hour); err != nil {
+		t.Error(err.Error())
+	}
+	// A value put without an expiry time never expires, even if it's too
+	// short to hold one.
+	err = sessions.Update(func(b *bolt.Bucket) error {
+		return b.Put([]byte("raw"), []byte("abc"))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Count the keys as stored, including any that expired.
+	stored := func() (n int) {
+		bx.DB.View(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("sessions")).ForEach(func(k, v []byte) error {
+				if v != nil {
+					n++
+				}
+				return nil
+			})
+		})
+		return n
+	}
+
+	if err := bx.StartSweeper(0); err == nil {
+		t.Error("expected error for zero interval")
+	}
+	if err := bx.StartSweeper(5 * time.Millisecond); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for stored() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired key not swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, k := range []string{"new", "raw"} {
+		if ok, _ := sessions.Has([]byte(k)); !ok {
+			t.Errorf("unexpired key %q swept", k)
+		}
+	}
+
+	bx.StopSweeper()
+	bx.StopSweeper() // no-op once stopped
+	if err := sessions.PutWithTTL([]byte("old"), []byte("a"), -time.Second); err != nil {
+		t.Error(err.Error())
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := stored(); n != 3 {
+		t.Errorf("got %d stored keys after stopping sweeper, want 3", n)
+	}
+}
+
+// Ensure every bucket opened for a TTL bucket reads and writes its
+// values the same way, including after the db is reopened.
+func TestTTLSettingsPersist(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+	bx, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	stale, err := bx.New([]byte("sessions"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sessions, err := bx.NewTTL([]byte("sessions"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := sessions.PutWithTTL([]byte("s0"), []byte("hi"), time.Hour); err != nil {
+		t.Error(err.Error())
+	}
+	err = bx.Batch(func(tx *buckets.Tx) error {
+		bk, err := tx.Bucket([]byte("sessions"))
+		if err != nil {
+			return err
+		}
+		return bk.Put([]byte("s1"), []byte("hello world!"))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := sessions.Get([]byte("s1")); string(v) != "hello world!" {
+		t.Errorf("got %q, want %q", v, "hello world!")
+	}
+	plain, err := bx.Bucket([]byte("sessions"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := plain.Get([]byte("s0")); string(v) != "hi" {
+		t.Errorf("got %q, want %q", v, "hi")
+	}
+
+	// A bucket opened before the bucket was made a TTL bucket can't
+	// write to it.
+	if err := stale.Put([]byte("s2"), []byte("x")); err == nil {
+		t.Error("got no error writing through stale bucket")
+	}
+
+	// Settings survive clearing and copying the bucket, but aren't
+	// listed as a nested bucket.
+	if names, _ := sessions.SubBuckets(); len(names) != 0 {
+		t.Errorf("got nested buckets %q, want none", names)
+	}
+	var mapped []string
+	sessions.Map(func(k, v []byte) error {
+		mapped = append(mapped, string(k))
+		return nil
+	})
+	if len(mapped) != 2 {
+		t.Errorf("got mapped keys %q, want s0 and s1", mapped)
+	}
+	if err := bx.CopyBucket([]byte("sessions"), []byte("copy")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := sessions.Clear(); err != nil {
+		t.Error(err.Error())
+	}
+	if err := sessions.Put([]byte("s3"), []byte("hello again")); err != nil {
+		t.Error(err.Error())
+	}
+
+	// A non-empty bucket can't be made a TTL bucket.
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := things.Put([]byte("a"), []byte("hello world!")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := bx.NewTTL([]byte("things")); err == nil {
+		t.Error("got no error making non-empty bucket a TTL bucket")
+	}
+
+	if err := bx.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	bx, err = buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+	for _, tt := range []struct{ bucket, key, want string }{
+		{"sessions", "s3", "hello again"},
+		{"copy", "s0", "hi"},
+		{"copy", "s1", "hello world!"},
+	} {
+		bk, err := bx.New([]byte(tt.bucket))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if v, _ := bk.Get([]byte(tt.key)); string(v) != tt.want {
+			t.Errorf("got %q, want %q", v, tt.want)
+		}
+		if err := bk.PutWithTTL([]byte("gone"), []byte("x"), -time.Second); err != nil {
+			t.Error(err.Error())
+		}
+		if ok, _ := bk.Has([]byte("gone")); ok {
+			t.Errorf("got expired key in reopened bucket %s", tt.bucket)
+		}
+	}
+
+	// The sweeper finds TTL buckets that weren't opened with NewTTL.
+	if err := bx.StartSweeper(5 * time.Millisecond); err != nil {
+		t.Fatal(err.Error())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var left int
+		bx.DB.View(func(tx *bolt.Tx) error {
+			for _, name := range []string{"sessions", "copy"} {
+				if tx.Bucket([]byte(name)).Get([]byte("gone")) != nil {
+					left++
+				}
+			}
+			return nil
+		})
+		if left == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired key in reopened bucket not swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Ensure closing a db stops its sweeper.
+func TestStartSweeperClose(t *testing.T) {
+	bx, err := buckets.OpenTemp()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := bx.NewTTL([]byte("sessions")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := bx.StartSweeper(time.Millisecond); err != nil {
+		t.Fatal(err.Error())
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if err := bx.Close(); err != nil {
+		t.Error(err.Error())
+	}
+	bx.StopSweeper()
+	if err := bx.StartSweeper(time.Millisecond); err == nil {
+		t.Error("expected error starting sweeper on a closed db")
+	}
+}
@@ -0,0 +1,130 @@
+package buckets
+
+import "github.com/boltdb/bolt"
+
+// A Tx is a read-write transaction spanning several buckets.  Buckets
+// opened within a Tx (see Tx.Bucket) work as usual, but their methods
+// read and write as part of the Tx, so their changes are only made, and
+// reported to the database's watchers, once the Tx commits.
+//
+// If one of the bucket methods fails to make its changes, the Tx can
+// only be rolled back: Commit rolls it back and returns the error.
+//
+// Like a bolt transaction, a Tx must only be used by one goroutine.
+// Since bolt runs one read-write transaction at a time, other writes to
+// the database (including those made by methods of buckets opened
+// outside the Tx) block until the Tx is committed or rolled back.
+type Tx struct {
+	db      *DB
+	tx      *bolt.Tx    // nil once committed or rolled back
+	err     error       // first error from a write, if any
+	changes []txChanges // to report to watchers on commit
+}
+
+// txChanges are the changes made within a Tx to the bucket with path
+// `bucket` (see Bucket.path).
+type txChanges struct {
+	bucket  [][]byte
+	changes []change
+}
+
+// Begin starts a read-write transaction.  The Tx must be committed or
+// rolled back.  Note that this hides the Begin method of the embedded
+// bolt.DB; call db.DB.Begin for a bolt transaction.
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{db: db, tx: tx}, nil
+}
+
+// Batch applies `fn` within a Tx, committing the Tx if `fn` returns nil
+// and rolling it back otherwise.  Buckets opened with the Tx's Bucket
+// method within `fn` read and write as part of the Tx, so their changes
+// are made together or not at all.  If `fn` panics, the Tx is rolled
+// back.  Note that this hides the Batch method of the embedded bolt.DB;
+// call db.DB.Batch for bolt's batched transactions.
+func (db *DB) Batch(fn func(tx *Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if tx.tx != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Bucket opens the named bucket within the Tx, without creating it.  If
+// the bucket doesn't exist, Bucket returns ErrBucketNotFound.
+func (tx *Tx) Bucket(name []byte) (*Bucket, error) {
+	bk := &Bucket{db: tx.db, Name: name, tx: tx}
+	err := tx.run(func(tx *bolt.Tx) error {
+		b := tx.Bucket(name)
+		if b == nil {
+			return ErrBucketNotFound
+		}
+		bk.loadSettings(b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bk, nil
+}
+
+// Commit writes the changes made within the Tx to disk, and then notifies
+// the database's watchers of them.  If a write within the Tx failed, the
+// Tx is rolled back instead and Commit returns the write's error.
+func (tx *Tx) Commit() error {
+	if tx.tx == nil {
+		return bolt.ErrTxClosed
+	}
+	t := tx.tx
+	tx.tx = nil
+	if tx.err != nil {
+		t.Rollback()
+		return tx.err
+	}
+	if err := t.Commit(); err != nil {
+		return err
+	}
+	for _, c := range tx.changes {
+		tx.db.watcher.notify(c.bucket, c.changes)
+	}
+	return nil
+}
+
+// Rollback discards the changes made within the Tx.
+func (tx *Tx) Rollback() error {
+	if tx.tx == nil {
+		return bolt.ErrTxClosed
+	}
+	t := tx.tx
+	tx.tx = nil
+	return t.Rollback()
+}
+
+// run applies `do` within the Tx.
+func (tx *Tx) run(do func(tx *bolt.Tx) error) error {
+	if tx.tx == nil {
+		return bolt.ErrTxClosed
+	}
+	return do(tx.tx)
+}
+
+// write applies `do`, which makes changes, within the Tx.  If `do`
+// fails, the Tx can only be rolled back.
+func (tx *Tx) write(do func(tx *bolt.Tx) error) error {
+	err := tx.run(do)
+	if err != nil && err != bolt.ErrTxClosed && tx.err == nil {
+		tx.err = err
+	}
+	return err
+}
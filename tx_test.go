@@ -0,0 +1,207 @@
+package buckets_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure changes to several buckets within a Tx are made atomically.
+func TestTx(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := bx.New([]byte("byday")); err != nil {
+		t.Fatal(err.Error())
+	}
+	var notified []string
+	bx.Watcher().OnPut([]byte("todos"), []byte("1"), func(v []byte) {
+		notified = append(notified, string(v))
+	})
+
+	tx, err := bx.Begin()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := tx.Bucket([]byte("missing")); err != buckets.ErrBucketNotFound {
+		t.Errorf("got %v, want ErrBucketNotFound", err)
+	}
+	txTodos, err := tx.Bucket([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	txByDay, err := tx.Bucket([]byte("byday"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := txTodos.Put([]byte("1"), []byte("milk cows")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := txByDay.Put([]byte("mon/1"), []byte{}); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Changes are visible within the Tx, but not outside it.
+	if v, _ := txTodos.Get([]byte("1")); string(v) != "milk cows" {
+		t.Errorf("got %q within tx, want milk cows", v)
+	}
+	if v, _ := todos.Get([]byte("1")); v != nil {
+		t.Errorf("got %q outside tx before commit, want nil", v)
+	}
+	if len(notified) != 0 {
+		t.Error("watcher notified before commit")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := todos.Get([]byte("1")); string(v) != "milk cows" {
+		t.Errorf("got %q after commit, want milk cows", v)
+	}
+	if len(notified) != 1 || notified[0] != "milk cows" {
+		t.Errorf("got notifications %q, want [milk cows]", notified)
+	}
+
+	// A finished Tx can't be used.
+	if err := tx.Commit(); err != bolt.ErrTxClosed {
+		t.Errorf("got %v, want ErrTxClosed", err)
+	}
+	if _, err := txTodos.Get([]byte("1")); err != bolt.ErrTxClosed {
+		t.Errorf("got %v, want ErrTxClosed", err)
+	}
+}
+
+// Ensure a Tx can be rolled back.
+func TestTxRollback(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tx, err := bx.Begin()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	txTodos, err := tx.Bucket([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := txTodos.Put([]byte("1"), []byte("milk cows")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := todos.Get([]byte("1")); v != nil {
+		t.Errorf("got %q after rollback, want nil", v)
+	}
+
+	// A failed write means the Tx is rolled back on commit.
+	tx, err = bx.Begin()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	txTodos, err = tx.Bucket([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := txTodos.Put([]byte("2"), []byte("feed chickens")); err != nil {
+		t.Error(err.Error())
+	}
+	failed := errors.New("failed")
+	err = txTodos.UpdateValue([]byte("3"), func([]byte) ([]byte, error) {
+		return nil, failed
+	})
+	if err != failed {
+		t.Errorf("got %v, want %v", err, failed)
+	}
+	if err := tx.Commit(); err != failed {
+		t.Errorf("got %v on commit, want %v", err, failed)
+	}
+	if v, _ := todos.Get([]byte("2")); v != nil {
+		t.Errorf("got %q after failed commit, want nil", v)
+	}
+}
+
+// Ensure a batch commits changes to several buckets together, or not at
+// all.
+func TestBatch(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	byday, err := bx.New([]byte("byday"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	put := func(tx *buckets.Tx, id, day string) error {
+		txTodos, err := tx.Bucket([]byte("todos"))
+		if err != nil {
+			return err
+		}
+		txByDay, err := tx.Bucket([]byte("byday"))
+		if err != nil {
+			return err
+		}
+		if err := txTodos.Put([]byte(id), []byte(day)); err != nil {
+			return err
+		}
+		return txByDay.Put([]byte(day+"/"+id), []byte{})
+	}
+
+	err = bx.Batch(func(tx *buckets.Tx) error {
+		return put(tx, "1", "mon")
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if ok, _ := todos.Has([]byte("1")); !ok {
+		t.Error("todo not put")
+	}
+	if ok, _ := byday.Has([]byte("mon/1")); !ok {
+		t.Error("index entry not put")
+	}
+
+	// An error rolls back all of the batch's changes.
+	failed := errors.New("failed")
+	err = bx.Batch(func(tx *buckets.Tx) error {
+		if err := put(tx, "2", "tue"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Errorf("got %v, want %v", err, failed)
+	}
+	if ok, _ := todos.Has([]byte("2")); ok {
+		t.Error("todo put by failed batch")
+	}
+	if ok, _ := byday.Has([]byte("tue/2")); ok {
+		t.Error("index entry put by failed batch")
+	}
+
+	// So does a panic.
+	func() {
+		defer func() { recover() }()
+		bx.Batch(func(tx *buckets.Tx) error {
+			put(tx, "3", "wed")
+			panic("oops")
+		})
+	}()
+	if ok, _ := todos.Has([]byte("3")); ok {
+		t.Error("todo put by panicking batch")
+	}
+}
@@ -1,8 +1,94 @@
 package buckets
 
-import "bytes"
+import (
+	"bytes"
+	"hash/fnv"
+)
 
-// isBefore checks whether `key` comes before `max`.
+// isBefore checks whether `key` comes before `max`.  A nil `max`
+// places no upper bound on `key`.
 func isBefore(key, max []byte) bool {
-	return key != nil && bytes.Compare(key, max) <= 0
+	return key != nil && (max == nil || bytes.Compare(key, max) <= 0)
+}
+
+// isAfter checks whether `key` comes after `min`.  A nil `min`
+// places no lower bound on `key`.
+func isAfter(key, min []byte) bool {
+	return key != nil && (min == nil || bytes.Compare(key, min) >= 0)
+}
+
+// hasPrefix checks whether `key` begins with `pre`.
+func hasPrefix(key, pre []byte) bool {
+	return key != nil && bytes.HasPrefix(key, pre)
+}
+
+// prefixEnd returns the smallest key that is greater than every key
+// with prefix `pre`, or nil if there is no such key (i.e., when `pre`
+// is empty or consists entirely of 0xff bytes).
+func prefixEnd(pre []byte) []byte {
+	end := make([]byte, len(pre))
+	copy(end, pre)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// seekLast moves cursor `c` to the last key before `bound`, or at
+// `bound` if `inclusive` is true, returning its key and value.  A nil
+// `bound` moves the cursor to the last key.
+func seekLast(c *cursor, bound []byte, inclusive bool) (key, value []byte) {
+	if bound == nil {
+		return c.Last()
+	}
+	k, v := c.Seek(bound)
+	if k == nil {
+		return c.Last()
+	}
+	if !inclusive || !bytes.Equal(k, bound) {
+		return c.Prev()
+	}
+	return k, v
+}
+
+// commonPrefix returns the longest prefix shared by `a` and `b`.  The
+// returned prefix shares memory with `a`.
+func commonPrefix(a, b []byte) []byte {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+// copied returns a func that applies `do` on copies of each key and
+// value, so that `do` can keep them after the transaction.
+func copied(do func(k, v []byte) error) func(k, v []byte) error {
+	return func(k, v []byte) error {
+		key := make([]byte, len(k))
+		copy(key, k)
+		value := make([]byte, len(v))
+		copy(value, v)
+		return do(key, value)
+	}
+}
+
+// copiedKeys is like copied, but for `do` that only needs the keys, so
+// the values aren't copied.
+func copiedKeys(do func(k []byte) error) func(k, v []byte) error {
+	return func(k, _ []byte) error {
+		key := make([]byte, len(k))
+		copy(key, k)
+		return do(key)
+	}
+}
+
+// hashKey returns a 32-bit FNV-1a hash of `key`.
+func hashKey(key []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return h.Sum32()
 }
@@ -0,0 +1,40 @@
+package buckets
+
+import (
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// WarmUp loads the keys in the bucket into memory, so that Exists can
+// find them without a transaction.  Keys deleted via the bucket are
+// removed from memory, but keys deleted otherwise (e.g., via another
+// Bucket for the same bucket, or via Update) must be removed with
+// Invalidate.  Keys added after WarmUp are still found, via a
+// transaction.  WarmUp should be called before the bucket is shared
+// between goroutines, e.g., at startup.
+func (bk *Bucket) WarmUp() (err error) {
+	defer bk.observe("WarmUp", nil)(&err)
+	warm := new(sync.Map)
+	err = bk.view(func(tx *bolt.Tx) error {
+		c := bk.cursor(tx)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v != nil {
+				warm.Store(string(k), struct{}{})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	bk.warm = warm
+	return nil
+}
+
+// Invalidate removes key `k` from the keys loaded into memory by WarmUp.
+func (bk *Bucket) Invalidate(k []byte) {
+	if bk.warm != nil {
+		bk.warm.Delete(string(k))
+	}
+}
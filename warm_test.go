@@ -0,0 +1,55 @@
+package buckets_test
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// Ensure a warmed up bucket finds keys, and forgets deleted ones.
+func TestWarmUp(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	for _, k := range []string{"A", "B", "C"} {
+		if err := things.Put([]byte(k), []byte(k)); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	if err := things.WarmUp(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Deleting via the bucket updates the warm keys.
+	if err := things.Delete([]byte("A")); err != nil {
+		t.Error(err.Error())
+	}
+	// Deleting otherwise requires invalidating the key.
+	err = things.Update(func(b *bolt.Bucket) error {
+		return b.Delete([]byte("B"))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	things.Invalidate([]byte("B"))
+	// Keys added later are found on disk.
+	if err := things.Put([]byte("D"), []byte("D")); err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := map[string]bool{"A": false, "B": false, "C": true, "D": true, "E": false}
+	for k, want := range expected {
+		got, err := things.Exists([]byte(k))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != want {
+			t.Errorf("key %q: got %v, want %v", k, got, want)
+		}
+	}
+}
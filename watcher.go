@@ -0,0 +1,374 @@
+package buckets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// An Op identifies the kind of change made to a key.
+type Op int
+
+const (
+	// OpPut indicates that a key was inserted or updated.
+	OpPut Op = iota + 1
+	// OpDelete indicates that a key was removed.
+	OpDelete
+)
+
+// String returns the name of the operation.
+func (op Op) String() string {
+	switch op {
+	case OpPut:
+		return "put"
+	case OpDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// A change records a put or delete of a key within a bucket.
+type change struct {
+	op    Op
+	key   []byte
+	value []byte
+}
+
+// A recorder wraps a bolt bucket within a read-write transaction,
+// recording the puts and deletes made through it so that watchers can
+// be notified once the transaction commits.  It also updates the
+// bucket's secondary indexes as part of the transaction.
+type recorder struct {
+	*bolt.Bucket
+	bk      *Bucket
+	record  bool
+	changes []change
+	indexes []*SecondaryIndex
+	keys    int  // keys in the bucket, if counted (see reserve)
+	counted bool // whether keys has been counted
+}
+
+// Get retrieves the value for key `k`.  A value that has expired or
+// can't be decoded is treated as missing.
+func (r *recorder) Get(k []byte) []byte {
+	v := r.Bucket.Get(k)
+	if r.bk.expired(v, time.Now().UnixNano()) {
+		return nil
+	}
+	v, err := r.bk.decode(v)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// Put sets the value for key `k`, recording the change.
+func (r *recorder) Put(k, v []byte) error {
+	return r.put(k, v, never)
+}
+
+// put sets the value for key `k` to expire at `expiry`, if the bucket's
+// keys can expire, recording the change.
+func (r *recorder) put(k, v []byte, expiry int64) error {
+	return r.store(k, v, nil, expiry)
+}
+
+// store is like put, but also stores tags `header`, encoded by
+// encodeTags, with the value, if the bucket's values can be tagged (see
+// NewTagged).
+func (r *recorder) store(k, v, header []byte, expiry int64) error {
+	raw := v
+	if r.bk.tagged {
+		raw = withTags(v, header)
+	}
+	stored, err := r.bk.encode(raw, expiry)
+	if err != nil {
+		return err
+	}
+	if err := r.reserve(k); err != nil {
+		return err
+	}
+	stale := r.indexKeys(k)
+	if err := r.Bucket.Put(k, stored); err != nil {
+		return err
+	}
+	if err := r.reindex(k, stale, v); err != nil {
+		return err
+	}
+	r.add(OpPut, k, v)
+	return nil
+}
+
+// Delete removes key `k`, recording the change if the key existed.
+func (r *recorder) Delete(k []byte) error {
+	existed := r.record && r.Bucket.Get(k) != nil
+	if r.counted && r.bk.get(r.Tx(), k) != nil {
+		r.keys--
+	}
+	stale := r.indexKeys(k)
+	if err := r.Bucket.Delete(k); err != nil {
+		return err
+	}
+	if err := r.reindex(k, stale, nil); err != nil {
+		return err
+	}
+	if r.bk.warm != nil {
+		r.bk.warm.Delete(string(k))
+	}
+	if existed {
+		r.add(OpDelete, k, nil)
+	}
+	return nil
+}
+
+// add records a change, copying `k` and `v` since they may only be
+// valid for the life of the transaction.
+func (r *recorder) add(op Op, k, v []byte) {
+	if !r.record {
+		return
+	}
+	c := change{op: op, key: make([]byte, len(k))}
+	copy(c.key, k)
+	if v != nil {
+		c.value = make([]byte, len(v))
+		copy(c.value, v)
+	}
+	r.changes = append(r.changes, c)
+}
+
+// A Watcher calls registered funcs when keys in the database change.
+// The funcs are called synchronously, by the goroutine that made the
+// change, after the transaction making the change has committed.
+type Watcher struct {
+	mu       sync.RWMutex
+	puts     []keyWatch
+	deletes  []keyWatch
+	prefixes []*prefixWatch
+}
+
+// A keyWatch is a func registered for changes to a single key.  The
+// bucket is given by its path, the names of its ancestors and then its
+// own name (see Bucket.path), so that nested buckets with the same name
+// aren't confused.
+type keyWatch struct {
+	bucket [][]byte
+	key    []byte
+	do     func(v []byte)
+}
+
+// A prefixWatch is a func registered for changes to keys with a prefix.
+type prefixWatch struct {
+	bucket [][]byte
+	prefix []byte
+	do     func(k, v []byte, op Op)
+}
+
+// Watcher returns the database's watcher, for registering funcs to be
+// called when keys change.
+func (db *DB) Watcher() *Watcher {
+	return db.watcher
+}
+
+// OnPut registers `do` to be called with the new value whenever key `k`
+// in the named bucket is put.  The named bucket is a top-level bucket,
+// not a nested bucket of the same name; to watch a nested bucket, see
+// Bucket.Subscribe.
+func (w *Watcher) OnPut(bucket, k []byte, do func(v []byte)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.puts = append(w.puts, keyWatch{[][]byte{bucket}, k, do})
+}
+
+// OnDelete registers `do` to be called whenever key `k` in the named
+// top-level bucket is deleted.
+func (w *Watcher) OnDelete(bucket, k []byte, do func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.deletes = append(w.deletes, keyWatch{[][]byte{bucket}, k, func([]byte) { do() }})
+}
+
+// OnPrefixChange registers `do` to be called whenever a key with prefix
+// `pre` in the named top-level bucket is put or deleted.  The value
+// passed to `do` is nil for deletes.
+func (w *Watcher) OnPrefixChange(bucket, pre []byte, do func(k, v []byte, op Op)) {
+	w.watchPrefix([][]byte{bucket}, pre, do)
+}
+
+// watchPrefix registers `do` like OnPrefixChange, for the bucket with
+// path `bucket`, returning a func that unregisters it.
+func (w *Watcher) watchPrefix(bucket [][]byte, pre []byte, do func(k, v []byte, op Op)) (cancel func()) {
+	pw := &prefixWatch{bucket, pre, do}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.prefixes = append(w.prefixes, pw)
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for i, p := range w.prefixes {
+			if p == pw {
+				w.prefixes = append(w.prefixes[:i:i], w.prefixes[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// watching reports whether any funcs are registered with the watcher.
+// A nil watcher, e.g., of a DB that wasn't opened with Open, has none.
+func (w *Watcher) watching() bool {
+	if w == nil {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.puts)+len(w.deletes)+len(w.prefixes) > 0
+}
+
+// notify calls the funcs registered for the changes made to the bucket
+// with path `bucket`.
+func (w *Watcher) notify(bucket [][]byte, changes []change) {
+	if w == nil || len(changes) == 0 {
+		return
+	}
+	// Copy the registrations so that funcs can register more watches.
+	w.mu.RLock()
+	puts := append([]keyWatch(nil), w.puts...)
+	deletes := append([]keyWatch(nil), w.deletes...)
+	prefixes := append([]*prefixWatch(nil), w.prefixes...)
+	w.mu.RUnlock()
+
+	for _, c := range changes {
+		watches := puts
+		if c.op == OpDelete {
+			watches = deletes
+		}
+		for _, kw := range watches {
+			if samePath(kw.bucket, bucket) && bytes.Equal(kw.key, c.key) {
+				kw.do(c.value)
+			}
+		}
+		for _, pw := range prefixes {
+			if samePath(pw.bucket, bucket) && bytes.HasPrefix(c.key, pw.prefix) {
+				pw.do(c.key, c.value, c.op)
+			}
+		}
+	}
+}
+
+// A WatchEvent describes a change made to a key in a bucket.  The Value
+// is nil for deletes.
+type WatchEvent struct {
+	Op    Op
+	Key   []byte
+	Value []byte
+}
+
+// WatchDebounced watches the bucket for changes, sending them in batches
+// on the returned channel.  A batch is sent once `debounce` has elapsed
+// since the first change in the batch.  Within a batch, there is only one
+// event per key, describing its last change.  The channel is closed once
+// `ctx` is done.
+//
+// Changes are batched in the background, so writers aren't blocked by
+// a slow receiver.  However, a receiver that never keeps up will cause
+// batches to grow without bound.
+func (bk *Bucket) WatchDebounced(ctx context.Context, debounce time.Duration) (<-chan []WatchEvent, error) {
+	if debounce <= 0 {
+		return nil, fmt.Errorf("invalid debounce interval: %s", debounce)
+	}
+
+	var mu sync.Mutex
+	var batch []WatchEvent
+	index := make(map[string]int) // key -> position in batch
+	kick := make(chan struct{}, 1)
+
+	cancel := bk.db.watcher.watchPrefix(bk.path(), nil, func(k, v []byte, op Op) {
+		mu.Lock()
+		defer mu.Unlock()
+		event := WatchEvent{op, k, v}
+		if i, ok := index[string(k)]; ok {
+			batch[i] = event
+			return
+		}
+		index[string(k)] = len(batch)
+		batch = append(batch, event)
+		select {
+		case kick <- struct{}{}:
+		default:
+		}
+	})
+
+	out := make(chan []WatchEvent)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-kick:
+			}
+			timer := time.NewTimer(debounce)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			mu.Lock()
+			events := batch
+			batch, index = nil, make(map[string]int)
+			mu.Unlock()
+			if len(events) == 0 {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- events:
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Subscribe watches the bucket for changes, sending an event for each
+// put or delete on the returned channel, which has a buffer of `buf`
+// events.  Events are sent once the transaction making the change has
+// committed, so rolled-back changes aren't sent.  Sending never blocks
+// the writer: if the channel's buffer is full, the event is dropped.
+// Events for changes made by a single goroutine are sent in the order
+// the changes were made; events for changes made concurrently by
+// several goroutines may be sent in any order.
+//
+// The returned func unsubscribes, closing the channel.
+func (bk *Bucket) Subscribe(buf int) (<-chan WatchEvent, func()) {
+	var mu sync.Mutex
+	closed := false
+	events := make(chan WatchEvent, buf)
+	cancel := bk.db.watcher.watchPrefix(bk.path(), nil, func(k, v []byte, op Op) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case events <- WatchEvent{op, k, v}:
+		default:
+		}
+	})
+	unsubscribe := func() {
+		cancel()
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(events)
+		}
+	}
+	return events, unsubscribe
+}
@@ -0,0 +1,337 @@
+package buckets_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure watchers are notified of puts and deletes to watched keys.
+func TestWatcher(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	name := []byte("things")
+	things, err := bx.New(name)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	var puts, deletes int
+	var got []byte
+	var changes []string
+
+	w := bx.Watcher()
+	w.OnPut(name, []byte("A"), func(v []byte) {
+		puts++
+		got = v
+	})
+	w.OnDelete(name, []byte("A"), func() {
+		deletes++
+	})
+	w.OnPrefixChange(name, []byte("B"), func(k, v []byte, op buckets.Op) {
+		changes = append(changes, fmt.Sprintf("%s %s=%s", op, k, v))
+	})
+
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if puts != 1 || !bytes.Equal(got, []byte("alpha")) {
+		t.Errorf("got %d puts with value %q, want 1 put with %q", puts, got, "alpha")
+	}
+
+	// Deleting a missing key shouldn't fire.
+	if err := things.Delete([]byte("missing")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Delete([]byte("A")); err != nil {
+		t.Error(err.Error())
+	}
+	if deletes != 1 {
+		t.Errorf("got %d deletes, want 1", deletes)
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("B1"), []byte("beta")},
+		{[]byte("C1"), []byte("gamma")},
+		{[]byte("B2"), []byte("BETA")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := things.DeletePrefix([]byte("B")); err != nil {
+		t.Error(err.Error())
+	}
+
+	expected := []string{
+		"put B1=beta",
+		"put B2=BETA",
+		"delete B1=",
+		"delete B2=",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("got %d changes, want %d: %q", len(changes), len(expected), changes)
+	}
+	for i, want := range expected {
+		if changes[i] != want {
+			t.Errorf("got %q, want %q", changes[i], want)
+		}
+	}
+}
+
+
+// Ensure a DB made from a bolt database, without a watcher, can still
+// be written to.
+func TestWatcherNil(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	bx := &buckets.DB{DB: db}
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := things.Put([]byte("a"), []byte("1")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Delete([]byte("a")); err != nil {
+		t.Error(err.Error())
+	}
+}
+// Ensure watchers aren't notified of changes to other buckets.
+func TestWatcherOtherBucket(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	fired := false
+	bx.Watcher().OnPut([]byte("others"), []byte("A"), func(v []byte) {
+		fired = true
+	})
+
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	if fired {
+		t.Error("watcher fired for a put to an unwatched bucket")
+	}
+}
+
+// Ensure watchers tell nested buckets from buckets of the same name.
+func TestWatcherNestedBucket(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todos, err := bx.New([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	lists, err := bx.New([]byte("lists"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	nested, err := lists.NewChild([]byte("todos"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var puts []string
+	bx.Watcher().OnPut([]byte("todos"), []byte("A"), func(v []byte) {
+		puts = append(puts, string(v))
+	})
+	events, unsubscribe := nested.Subscribe(4)
+	defer unsubscribe()
+
+	if err := nested.Put([]byte("A"), []byte("nested")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := todos.Put([]byte("A"), []byte("top")); err != nil {
+		t.Error(err.Error())
+	}
+	if len(puts) != 1 || puts[0] != "top" {
+		t.Errorf("got puts %q, want only the top-level put", puts)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want only the nested put", len(events))
+	}
+	if e := <-events; string(e.Value) != "nested" {
+		t.Errorf("got event for %q, want nested put", e.Value)
+	}
+}
+
+// Ensure debounced watches batch and deduplicate changes.
+func TestWatchDebounced(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := things.WatchDebounced(ctx, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Put([]byte("A"), []byte("ALPHA")); err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Delete([]byte("B")); err != nil {
+		t.Error(err.Error())
+	}
+
+	var batch []buckets.WatchEvent
+	select {
+	case batch = <-events:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for batch")
+	}
+
+	expected := []buckets.WatchEvent{
+		{Op: buckets.OpPut, Key: []byte("A"), Value: []byte("ALPHA")},
+		{Op: buckets.OpDelete, Key: []byte("B")},
+	}
+	if len(batch) != len(expected) {
+		t.Fatalf("got %d events, want %d", len(batch), len(expected))
+	}
+	for i, want := range expected {
+		got := batch[i]
+		if got.Op != want.Op || !bytes.Equal(got.Key, want.Key) || !bytes.Equal(got.Value, want.Value) {
+			t.Errorf("got %s %s=%s, want %s %s=%s",
+				got.Op, got.Key, got.Value, want.Op, want.Key, want.Value)
+		}
+	}
+
+	// The channel is closed once the context is done.
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("got batch after cancel, want closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for channel to close")
+	}
+
+	if _, err := things.WatchDebounced(ctx, 0); err == nil {
+		t.Error("expected error for zero debounce interval")
+	}
+}
+
+// Show how to watch a key for changes.
+func ExampleWatcher_OnPut() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+
+	bx.Watcher().OnPut([]byte("things"), []byte("A"), func(v []byte) {
+		fmt.Printf("A is now %q\n", v)
+	})
+
+	things.Put([]byte("A"), []byte("alpha"))
+	things.Put([]byte("B"), []byte("beta"))
+	things.Put([]byte("A"), []byte("ALPHA"))
+
+	// Output:
+	// A is now "alpha"
+	// A is now "ALPHA"
+}
+
+// Ensure subscribers are sent events for committed changes.
+func TestSubscribe(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	events, unsubscribe := things.Subscribe(4)
+
+	if err := things.Put([]byte("a"), []byte("1")); err != nil {
+		t.Error(err.Error())
+	}
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("b"), []byte("2")},
+		{[]byte("c"), []byte("3")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+	// A rolled-back change sends no event.
+	failed := fmt.Errorf("failed")
+	err = things.UpdateValue([]byte("d"), func([]byte) ([]byte, error) {
+		return []byte("4"), failed
+	})
+	if err != failed {
+		t.Errorf("got %v, want %v", err, failed)
+	}
+	if err := things.Delete([]byte("a")); err != nil {
+		t.Error(err.Error())
+	}
+	// The buffer is full, so this event is dropped.
+	if err := things.Put([]byte("e"), []byte("5")); err != nil {
+		t.Error(err.Error())
+	}
+
+	want := []buckets.WatchEvent{
+		{Op: buckets.OpPut, Key: []byte("a"), Value: []byte("1")},
+		{Op: buckets.OpPut, Key: []byte("b"), Value: []byte("2")},
+		{Op: buckets.OpPut, Key: []byte("c"), Value: []byte("3")},
+		{Op: buckets.OpDelete, Key: []byte("a")},
+	}
+	for _, w := range want {
+		got := <-events
+		if got.Op != w.Op || !bytes.Equal(got.Key, w.Key) || !bytes.Equal(got.Value, w.Value) {
+			t.Errorf("got %v %q=%q, want %v %q=%q", got.Op, got.Key, got.Value, w.Op, w.Key, w.Value)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Errorf("got unexpected event for %q", got.Key)
+	default:
+	}
+
+	unsubscribe()
+	unsubscribe() // no-op once unsubscribed
+	if _, ok := <-events; ok {
+		t.Error("channel not closed after unsubscribing")
+	}
+	if err := things.Put([]byte("f"), []byte("6")); err != nil {
+		t.Error(err.Error())
+	}
+}